@@ -1,15 +1,14 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 )
 
@@ -62,43 +61,20 @@ func (glossary Glossary) GetHeaders() []string {
 	return headers
 }
 
+// GetValues returns the fields of the glossary for CSV and Markdown files, on
+// one line each. The JSON files keep the fields as scraped.
 func (glossary Glossary) GetValues() []string {
 	values := make([]string, 0)
 
-	values = append(values, glossary.Title)
-	values = append(values, glossary.Slug)
-	values = append(values, glossary.Excerpt)
-	values = append(values, glossary.Difficulty.Label)
+	values = append(values, strings.Replace(glossary.Title, "\n", "", -1))
+	values = append(values, strings.Replace(glossary.Slug, "\n", "", -1))
+	values = append(values, strings.Replace(glossary.Excerpt, "\n", "", -1))
+	values = append(values, strings.Replace(glossary.Difficulty.Label, "\n", "", -1))
 	values = append(values, glossary.Content)
 
 	return values
 }
 
-func writeToCsv(csvFile os.File, glossary Glossary) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(glossary.GetHeaders())
-	csvWriter.Write(glossary.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, glossary Glossary) {
-	glossary.Excerpt = strings.Replace(glossary.Excerpt, "\n", "", -1)
-
-	glossary.Excerpt = strings.Replace(glossary.Excerpt, "\"", "", -1)
-
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	slug: "%s"
-	difficulty: "%s"
-	excerpt: "%s"
-	---
-	%s`, glossary.Title, glossary.Slug, glossary.Difficulty.Label, strings.Replace(glossary.Excerpt, "\n", "", -1), glossary.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getGlossaries(c *colly.Collector) {
 	glossaries := make([]Glossary, 0)
 
@@ -123,33 +99,21 @@ func getGlossaries(c *colly.Collector) {
 			var content = ""
 
 			c.OnHTML(".css-g5rsps", func(h *colly.HTMLElement) {
-				content = h.Text
-
-				h.ForEach(".css-13l1dht", func(i int, h *colly.HTMLElement) {
-					text := h.Text
-					if strings.Contains(text, "Community Submission - Author:") {
-						content = strings.Replace(content, text, "", 1)
-					}
-					if strings.Contains(text, "Learn more:") {
-						content = strings.Replace(content, text, "", 1)
-					}
-					if strings.Contains(text, "Learn More:") {
-						content = strings.Replace(content, text, "", 1)
-					}
-					if strings.Contains(text, "Also learn:") {
-						content = strings.Replace(content, text, "", 1)
-					}
-					if strings.Contains(text, "Also Learn:") {
-						content = strings.Replace(content, text, "", 1)
+				article := h.DOM.Clone()
+
+				article.Find(".css-13l1dht").Each(func(i int, s *goquery.Selection) {
+					text := s.Text()
+					for _, link := range []string{"Community Submission - Author:", "Learn more:", "Learn More:", "Also learn:", "Also Learn:"} {
+						if strings.Contains(text, link) {
+							s.Remove()
+							break
+						}
 					}
 				})
 
-				nav := h.ChildText(".css-1j2hb68")
-				title := h.ChildText("h1")
-				difficulty := h.ChildText(".css-11w9015")
-				content = strings.Replace(content, nav, "", 1)
-				content = strings.Replace(content, title, "", 1)
-				content = strings.Replace(content, difficulty, "", 1)
+				article.Find(".css-1j2hb68, h1, .css-11w9015").Remove()
+
+				content = utils.SelectionMarkdown(article, h.Request.URL.String())
 			})
 
 			c.Visit(fmt.Sprintf("https://academy.binance.com/en/glossary/%s", glossary.Slug))
@@ -160,68 +124,9 @@ func getGlossaries(c *colly.Collector) {
 
 			glossaries = append(glossaries, glossary)
 
-			file, err := json.MarshalIndent(glossary, "", " ")
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			glossary.Title = strings.Replace(glossary.Title, "\n", "", -1)
-			glossary.Slug = strings.Replace(glossary.Slug, "\n", "", -1)
-			glossary.Excerpt = strings.Replace(glossary.Excerpt, "\n", "", -1)
-			glossary.Difficulty.Label = strings.Replace(glossary.Difficulty.Label, "\n", "", -1)
-			glossary.Content = strings.Replace(glossary.Content, "\n", "", -1)
-
-			if err := os.Mkdir(fmt.Sprintf("../../output/academy.binance.com/%s", glossary.Title), os.ModePerm); err != nil {
-				if !strings.Contains(err.Error(), "file exists") {
-					log.Fatal(err)
-				}
-			}
-
-			filepathJson := fmt.Sprintf("../../output/academy.binance.com/%s/article.json", glossary.Title)
-			filepathCsv := fmt.Sprintf("../../output/academy.binance.com/%s/article.csv", glossary.Title)
-			filepathMd := fmt.Sprintf("../../output/academy.binance.com/%s/article.md", glossary.Title)
-
-			f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+			utils.Observe(glossary)
 
-			if err != nil && f == nil {
-
-				f.Close()
-
-				createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-				createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-				createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-				if json_err != nil {
-					log.Fatal(err)
-				}
-
-				if csv_err != nil {
-					log.Fatal(err)
-				}
-
-				if md_err != nil {
-					log.Fatal(err)
-				}
-
-				createdJson.Write(file)
-
-				writeToCsv(*createdCsv, glossary)
-
-				writeToMd(*createdMd, glossary)
-
-				m := map[string]string{
-					"title": "term",
-				}
-
-				utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-				createdJson.Close()
-				createdMd.Close()
-				createdCsv.Close()
-
-			} else {
-				f.Close()
-			}
+			utils.Save(strings.Replace(glossary.Title, "\n", "", -1), glossary)
 		}
 	})
 
@@ -229,13 +134,22 @@ func getGlossaries(c *colly.Collector) {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/academy.binance.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "academy.binance.com",
+		Path: utils.NestedPath,
+		Renames: map[string]string{
+			"title": "term",
+		},
+		Coverage: map[string]float64{"title": 1, "slug": 1, "excerpt": 0.8, "content": 0.95},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".css-g5rsps",
+	})
+
 	getGlossaries(c)
+
+	utils.Finish()
 }
@@ -2,16 +2,12 @@ package main
 
 import (
 	"bytes"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 	"golang.org/x/net/html"
 )
@@ -57,30 +53,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	image: "%s"
-	published: "%s"
-	author: "%s"
-	category: "%s"
-	---
-	%s`, article.Id, article.Title, article.Link, article.Image, article.Published, article.Author, article.Category, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -90,6 +62,10 @@ func getRssArticles() []Article {
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".single-post-main-middle",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -99,6 +75,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		title := x.ChildText("title")
 		link := x.ChildText("link")
 		guid := x.ChildText("guid")
@@ -108,9 +88,7 @@ func getRssArticles() []Article {
 
 		x.Request.Visit(link)
 
-		content := strings.Join(paragraphs, "")
-
-		content = strings.Replace(content, "\n", "", -1)
+		content := strings.Join(paragraphs, "\n\n")
 
 		article := Article{Title: title, Link: link, Id: guid, Published: published, Author: author, Category: category, Content: content, Image: image}
 
@@ -133,9 +111,9 @@ func getRssArticles() []Article {
 				log.Fatal(err)
 			}
 
-			p := strings.NewReader(b.String())
-			doc, _ := goquery.NewDocumentFromReader(p)
-			paragraphs = append(paragraphs, doc.Text())
+			if paragraph := utils.HTMLMarkdown(b.String(), h.Request.URL.String()); paragraph != "" {
+				paragraphs = append(paragraphs, paragraph)
+			}
 		}
 	})
 
@@ -150,60 +128,22 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/ambcrypto.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "ambcrypto.com",
+		Renames: map[string]string{
+			"category":  "categories",
+			"published": "created_at",
+		},
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/ambcrypto.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/ambcrypto.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/ambcrypto.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
+		utils.Observe(article)
 
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{
-				"category":  "categories",
-				"published": "created_at",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -10,7 +8,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -46,27 +43,6 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	image: "%s"
-	---
-	%s`, page.Id, page.Title, page.Link, page.Image, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	image := ""
@@ -84,12 +60,7 @@ func scrapePage(c *colly.Collector, href string) {
 
 		page.Id = id
 
-		title := h.ChildText("h1")
-
-		page.Content = strings.Replace(h.Text, title, "", -1)
-		page.Content = strings.Replace(page.Content, "    ", "", -1)
-		page.Content = strings.Replace(page.Content, "\n", "", -1)
-		page.Content = strings.Replace(page.Content, "\t", "", -1)
+		page.Content = utils.ElementMarkdown(h, "h1")
 	})
 
 	c.OnHTML("figure", func(h *colly.HTMLElement) {
@@ -107,79 +78,32 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/armantheparman.com/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/armantheparman.com/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/armantheparman.com/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/armantheparman.com/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		if csv_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{
-			"category":  "categories",
-			"published": "created_at",
-		}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/armantheparman.com", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "armantheparman.com",
+		Path: utils.NestedPath,
+		Renames: map[string]string{
+			"category":  "categories",
+			"published": "created_at",
+		},
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   "title",
+		"content": "article",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		if strings.Contains(err.Error(), "Too Many Requests") {
 			os.Exit(0)
@@ -204,6 +128,7 @@ func main() {
 	})
 
 	c.Visit("https://armantheparman.com/")
+	utils.Source("https://armantheparman.com/")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -224,6 +149,12 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
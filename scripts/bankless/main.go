@@ -1,13 +1,9 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
-	"strings"
 	"time"
 
 	"github.com/gocolly/colly"
@@ -42,6 +38,11 @@ func (article Article) GetValues() []string {
 func saveArticles() {
 	c := colly.NewCollector(colly.MaxDepth(0))
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   "title",
+		"content": ".contents",
+	})
+
 	c.SetRequestTimeout(time.Minute * 3)
 
 	c.OnError(func(r *colly.Response, err error) {
@@ -64,7 +65,7 @@ func saveArticles() {
 			})
 
 			c.OnHTML(".contents", func(h *colly.HTMLElement) {
-				article.Content = strings.Replace(h.Text, "\n", "", -1)
+				article.Content = utils.ElementMarkdown(h)
 			})
 
 			c.Visit(link)
@@ -78,86 +79,20 @@ func saveArticles() {
 	c.Visit("https://www.bankless.com/read/briefs")
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	---
-	%s`, article.Title, article.Link, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func savePage(article Article) {
-	file, err := json.MarshalIndent(article, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/bankless.com/%s", article.Title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
-
-	filepathJson := fmt.Sprintf("../../output/bankless.com/%s/article.json", article.Title)
-	filepathCsv := fmt.Sprintf("../../output/bankless.com/%s/article.csv", article.Title)
-	filepathMd := fmt.Sprintf("../../output/bankless.com/%s/article.md", article.Title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
+	utils.Observe(article)
 
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		if csv_err != nil {
-			defer createdJson.Close()
-
-		} else {
-			writeToCsv(*createdCsv, article)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		writeToMd(*createdMd, article)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(article.Title, article)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/bankless.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "bankless.com",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	saveArticles()
+
+	utils.Finish()
 }
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -70,6 +67,10 @@ func getRSSArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 		article.Id = x.ChildText("guid")
 		article.Title = x.ChildText("title")
@@ -82,7 +83,7 @@ func getRSSArticles() []Article {
 
 		cont, _ := r.Parse(strings.NewReader(x.ChildText("content:encoded")), article.Link)
 
-		article.Content = strings.Replace(cont.TextContent, "\n", "", -1)
+		article.Content = utils.HTMLMarkdown(cont.Content, article.Link)
 
 		fmt.Printf("Downloaded: %s\n", article.Title)
 
@@ -94,89 +95,22 @@ func getRSSArticles() []Article {
 	return articles
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	image: "%s"
-	published: "%s"
-	description: "%s"
-	---
-	%s`, article.Id, article.Title, article.Link, article.Image, article.Published, article.Description, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func main() {
-	if err := os.Mkdir("../../output/banklessdao.substack.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "banklessdao.substack.com",
+		Path: utils.NestedPath,
+		Renames: map[string]string{
+			"link": "social",
+		},
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "description": 0.9, "content": 0.95},
+	})
 
 	rssArticles := getRSSArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if err := os.Mkdir(fmt.Sprintf("../../output/banklessdao.substack.com/%s", article.Title), os.ModePerm); err != nil {
-			if !strings.Contains(err.Error(), "file exists") {
-				log.Fatal(err)
-			}
-		}
+		utils.Observe(article)
 
-		filepathJson := fmt.Sprintf("../../output/banklessdao.substack.com/%s/article.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/banklessdao.substack.com/%s/article.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/banklessdao.substack.com/%s/article.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{
-				"link": "social",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
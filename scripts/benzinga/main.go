@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -48,21 +45,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	author: "%s"
-	---
-	%s`, article.Id, article.Title, article.Link, article.Published, article.Author, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getArticleLinks(c *colly.Collector) []string {
 	articles := make([]string, 0)
 
@@ -85,6 +67,7 @@ func getArticleLinks(c *colly.Collector) []string {
 	})
 
 	c.Visit("https://benzinga.com")
+	utils.Source("https://benzinga.com")
 
 	return articles
 }
@@ -99,11 +82,13 @@ func getArticle(c *colly.Collector, link string) Article {
 
 	c.OnHTML("#article-body", func(h *colly.HTMLElement) {
 		if content == "" {
-			content = h.ChildText(".core-block")
-			content = strings.Replace(content, "\n", "", -1)
-			content = strings.Replace(content, "\t", "", -1)
-			content = strings.Replace(content, "\"", "'", -1)
-			content = strings.Replace(content, "\\", "", -1)
+			blocks := make([]string, 0)
+			h.ForEach(".core-block", func(i int, h *colly.HTMLElement) {
+				if block := utils.ElementMarkdown(h); block != "" {
+					blocks = append(blocks, block)
+				}
+			})
+			content = strings.Join(blocks, "\n\n")
 			article.Content = content
 		}
 	})
@@ -140,77 +125,38 @@ func getArticle(c *colly.Collector, link string) Article {
 	return article
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/benzinga.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "benzinga.com",
+		Renames: map[string]string{
+			"title": "name",
+		},
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.9, "author": 0.8, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   ".layout-title",
+		"content": "#article-body",
+	})
+
 	links := getArticleLinks(c)
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		article := getArticle(c, link)
 
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
+		utils.Observe(article)
 
 		if article.Title == "" {
 			continue
 		}
 
-		filepathJson := fmt.Sprintf("../../output/benzinga.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/benzinga.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/benzinga.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{
-				"title": "name",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
 
 	// for _, article := range rssArticles {
@@ -255,4 +201,6 @@ func main() {
 	// 		 f.Close()
 	// 	}
 	// }
+
+	utils.Finish()
 }
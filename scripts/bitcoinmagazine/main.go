@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -58,29 +55,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	author: "%s"
-	---
-	%s
-	`, article.Id, article.Title, article.Link, article.Published, article.Author, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -95,6 +69,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		guid := x.ChildText("guid")
 		title := x.ChildText("title")
 		link := x.ChildText("link")
@@ -109,9 +87,7 @@ func getRssArticles() []Article {
 
 		title = strings.Replace(title, "\"", "'", -1)
 
-		textcont := strings.Replace(content.TextContent, "\n", "", -1)
-		textcont = strings.Replace(textcont, "\t", "", -1)
-		textcont = strings.Replace(textcont, "\"", "'", -1)
+		textcont := utils.HTMLMarkdown(content.Content, link)
 
 		category := strings.Join(x.ChildTexts("category"), ", ")
 		media_thumbnail := x.ChildAttr("media:content", "url")
@@ -129,59 +105,21 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/bitcoinmagazine.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "bitcoinmagazine.com",
+		Renames: map[string]string{
+			"category": "categories",
+		},
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.95},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/bitcoinmagazine.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/bitcoinmagazine.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/bitcoinmagazine.com/%s.md", article.Title)
+		utils.Observe(article)
 
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{
-				"category": "categories",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -58,31 +55,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -97,6 +69,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 
 		article.Id = x.ChildText("post-id")
@@ -115,9 +91,7 @@ func getRssArticles() []Article {
 		article.Description = strings.Replace(article.Description, "\t", "", -1)
 		article.Description = strings.Replace(article.Description, "\"", "'", -1)
 
-		article.Content = strings.Replace(cont.TextContent, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		article.Content = utils.HTMLMarkdown(cont.Content, article.Link)
 
 		articles = append(articles, article)
 
@@ -130,57 +104,17 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/blockonomi.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "blockonomi.com",
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "image": 0.8, "content": 0.95},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/blockonomi.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/blockonomi.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/blockonomi.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+		utils.Observe(article)
 
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,17 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -53,29 +49,11 @@ func (page PageData) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, page PageData) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, page PageData) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Published, page.Description, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func scrapePage(c *colly.Collector, href string) {
+	if utils.SourceDone() {
+		return
+	}
+
 	time.Sleep(time.Second * 1)
 	var page PageData
 	sublinks := make([]string, 0)
@@ -119,7 +97,7 @@ func scrapePage(c *colly.Collector, href string) {
 	})
 
 	c.OnHTML("title", func(h *colly.HTMLElement) {
-		page.Title, _ = filenamify.FilenamifyV2(h.Text)
+		page.Title = h.Text
 	})
 
 	c.OnHTML(".r-1pa6394", func(h *colly.HTMLElement) {
@@ -139,12 +117,10 @@ func scrapePage(c *colly.Collector, href string) {
 	c.OnHTML("main", func(h *colly.HTMLElement) {
 		r := readability.New()
 
-		cont, _ := r.Parse(strings.NewReader(h.Text), page.Link)
+		main, _ := h.DOM.Html()
+		cont, _ := r.Parse(strings.NewReader(main), page.Link)
 
-		page.Content = strings.Replace(cont.TextContent, "Comment on page", "", -1)
-		page.Content = strings.Replace(page.Content, "\n", "", -1)
-		page.Content = strings.Replace(page.Content, "\t", "", -1)
-		page.Content = strings.Replace(page.Content, "\"", "'", -1)
+		page.Content = strings.Replace(utils.HTMLMarkdown(cont.Content, page.Link), "Comment on page", "", -1)
 	})
 
 	visited = append(visited, page.Link)
@@ -157,14 +133,6 @@ func scrapePage(c *colly.Collector, href string) {
 		return
 	}
 
-	if err := os.Mkdir(fmt.Sprintf("../../output/coincashew.com/%s", page.Title), os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		} else {
-			return
-		}
-	}
-
 	savePage(page)
 
 	if len(sublinks) > 0 {
@@ -189,62 +157,27 @@ func getMainPageLinks(c *colly.Collector) []string {
 }
 
 func savePage(page PageData) {
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
+	utils.Observe(page)
 
-	filepathJson := fmt.Sprintf("../../output/coincashew.com/%s/article.json", page.Title)
-	filepathCsv := fmt.Sprintf("../../output/coincashew.com/%s/article.csv", page.Title)
-	filepathMd := fmt.Sprintf("../../output/coincashew.com/%s/article.md", page.Title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coincashew.com", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "coincashew.com",
+		Type:     "page",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":       "title",
+		"description": ".contentParagraph_-qmPj",
+		"content":     "main",
+	})
+
 	c.SetRequestTimeout(time.Minute * 3)
 
 	c.OnError(func(r *colly.Response, err error) {
@@ -255,7 +188,11 @@ func main() {
 
 	links := getMainPageLinks(c)
 
+	// every guide of the main page is a source of its own for --limit
 	for _, link := range links {
+		utils.Source(link)
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -57,32 +54,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	category: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Category, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	var content string
 
@@ -90,6 +61,10 @@ func getRssArticles() []Article {
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".at-body",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -99,10 +74,14 @@ func getRssArticles() []Article {
 	})
 
 	c.OnHTML(".at-body", func(h *colly.HTMLElement) {
-		content = h.Text
+		content = utils.ElementMarkdown(h)
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		title := x.ChildText("title")
 		link := x.ChildText("link")
 		guid := x.ChildText("guid")
@@ -135,57 +114,18 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coindesk.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:       "coindesk.com",
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/coindesk.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/coindesk.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/coindesk.com/%s.md", article.Title)
+		utils.Observe(article)
 
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,11 +1,7 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -36,25 +32,6 @@ func (glossary Glossary) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, glossary Glossary) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(glossary.GetHeaders())
-	csvWriter.Write(glossary.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, glossary Glossary) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	---
-	%s`,
-		glossary.Title, glossary.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getGlossaries(c *colly.Collector) []Glossary {
 	glossaries := make([]Glossary, 0)
 
@@ -68,7 +45,13 @@ func getGlossaries(c *colly.Collector) []Glossary {
 		glossary := Glossary{}
 
 		glossary.Title = h.ChildText("h5")
-		glossary.Content = h.ChildText("p")
+		paragraphs := make([]string, 0)
+		h.ForEach("p", func(i int, h *colly.HTMLElement) {
+			if paragraph := utils.ElementMarkdown(h); paragraph != "" {
+				paragraphs = append(paragraphs, paragraph)
+			}
+		})
+		glossary.Content = strings.Join(paragraphs, "\n\n")
 
 		print(glossary.Title)
 
@@ -85,70 +68,21 @@ func getGlossaries(c *colly.Collector) []Glossary {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coindeskglossary", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "coindeskglossary",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "content": 0.95},
+	})
 
 	c := colly.NewCollector()
 
 	glosaries := getGlossaries(c)
 
 	for _, glossary := range glosaries {
-		file, err := json.MarshalIndent(glossary, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if err := os.Mkdir(fmt.Sprintf("../../output/coindeskglossary/%s", glossary.Title), os.ModePerm); err != nil {
-			if !strings.Contains(err.Error(), "file exists") {
-				log.Fatal(err)
-			}
-		}
-
-		filepathJson := fmt.Sprintf("../../output/coindeskglossary/%s/article.json", glossary.Title)
-		filepathCsv := fmt.Sprintf("../../output/coindeskglossary/%s/article.csv", glossary.Title)
-		filepathMd := fmt.Sprintf("../../output/coindeskglossary/%s/article.md", glossary.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-
-			f.Close()
+		utils.Observe(glossary)
 
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, glossary)
-
-			writeToMd(*createdMd, glossary)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdMd.Close()
-			createdCsv.Close()
-
-		} else {
-			f.Close()
-		}
+		utils.Save(glossary.Title, glossary)
 	}
+
+	utils.Finish()
 }
@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/cixtor/readability v1.0.0
 	github.com/gocolly/colly v1.2.0
+	golang.org/x/net v0.19.0
 )
 
 require (
@@ -19,7 +20,6 @@ require (
 	github.com/kennygrant/sanitize v1.2.4 // indirect
 	github.com/saintfish/chardet v0.0.0-20230101081208-5e3ef4b5456d // indirect
 	github.com/temoto/robotstxt v1.1.2 // indirect
-	golang.org/x/net v0.19.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
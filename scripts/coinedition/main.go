@@ -1,11 +1,9 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"log"
-	"os"
 	"regexp"
 	"scripts/utils"
 	"strings"
@@ -60,32 +58,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	category: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Category, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -93,6 +65,10 @@ func getRssArticles() []Article {
 
 	c := colly.NewCollector(colly.MaxDepth(0))
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".ce-single-post-content-block",
+	})
+
 	c.SetRequestTimeout(time.Hour)
 
 	c.OnError(func(r *colly.Response, err error) {
@@ -108,15 +84,21 @@ func getRssArticles() []Article {
 			if node.Data == "script" || node.Data == "figure" || node.Data == "div" || node.Data == "ins" {
 				continue
 			}
-			for n := node.FirstChild; n != nil; n = n.NextSibling {
-				if n.Type == html.NodeType(1) {
-					paragraphs = append(paragraphs, n.Data)
-				}
+			var b bytes.Buffer
+			if err := html.Render(&b, node); err != nil {
+				log.Fatal(err)
+			}
+			if paragraph := utils.HTMLMarkdown(b.String(), h.Request.URL.String()); paragraph != "" {
+				paragraphs = append(paragraphs, paragraph)
 			}
 		}
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 
 		article.Id = x.ChildText("guid")
@@ -147,11 +129,7 @@ func getRssArticles() []Article {
 		x.Request.Visit(article.Link)
 
 		article.Image = src
-		article.Content = strings.Join(paragraphs, "")
-
-		article.Content = strings.Replace(article.Content, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		article.Content = strings.Join(paragraphs, "\n\n")
 
 		article.Description = strings.Replace(article.Description, "\n", "", -1)
 		article.Description = strings.Replace(article.Description, "\t", "", -1)
@@ -169,59 +147,21 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coinedition.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "coinedition.com",
+		Renames: map[string]string{
+			"image": "thumbnail",
+		},
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/coinedition.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/coinedition.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/coinedition.com/%s.md", article.Title)
+		utils.Observe(article)
 
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{
-				"image": "thumbnail",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
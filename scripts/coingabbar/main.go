@@ -1,13 +1,10 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"scripts/utils"
 	"strconv"
 	"strings"
@@ -71,7 +68,7 @@ func (article Article) GetValues() []string {
 	values = append(values, article.Title)
 	values = append(values, article.AltTitle)
 	values = append(values, article.Link)
-	values = append(values, string(rune(article.LikesCounts)))
+	values = append(values, strconv.Itoa(article.LikesCounts))
 	values = append(values, article.Published)
 	values = append(values, article.Image)
 	values = append(values, article.Author)
@@ -82,26 +79,12 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	likes_counts := strconv.Itoa(article.LikesCounts)
-
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	alt_title: "%s"
-	link: "%s"
-	likes_counts: "%s"
-	published: "%s"
-	image: "%s"
-	author: "%s"
-	description: "%s"
-	author_url: "%s"
-	---
-	%s`,
-		article.Title, article.AltTitle, article.Link, likes_counts, article.Published, article.Image, article.Author, article.Description, article.AuthorUrl, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
+func (article JsonArticle) GetHeaders() []string {
+	return Article(article).GetHeaders()
+}
+
+func (article JsonArticle) GetValues() []string {
+	return Article(article).GetValues()
 }
 
 func getArticles(c *colly.Collector) []Article {
@@ -120,15 +103,16 @@ func getArticles(c *colly.Collector) []Article {
 	json.Unmarshal(body, &translated)
 
 	for _, article := range translated.Data {
+		if utils.LimitReached(len(returned)) {
+			break
+		}
+
 		c.OnHTML(".news", func(h *colly.HTMLElement) {
 			r := readability.New()
 
-			content, _ := r.Parse(strings.NewReader(h.Text), article.Link)
-			article.Content = content.TextContent
-
-			article.Content = strings.Replace(article.Content, "\n", "", -1)
-			article.Content = strings.Replace(article.Content, "\t", "", -1)
-			article.Content = strings.Replace(article.Content, "\"", "'", -1)
+			news, _ := h.DOM.Html()
+			content, _ := r.Parse(strings.NewReader(news), article.Link)
+			article.Content = utils.HTMLMarkdown(content.Content, article.Link)
 
 			article.Description = strings.Replace(article.Description, "\n", "", -1)
 			article.Description = strings.Replace(article.Description, "\t", "", -1)
@@ -145,71 +129,29 @@ func getArticles(c *colly.Collector) []Article {
 	return returned
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/coingabbar.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "coingabbar.com",
+		Type:     "article",
+		Coverage: map[string]float64{"title": 1, "url": 1, "published": 1, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".news",
+	})
+
 	articles := getArticles(c)
 
 	for _, article := range articles {
 
 		json_article := JsonArticle{Title: article.Title, AltTitle: article.AltTitle, Link: article.Link, LikesCounts: article.LikesCounts, Published: article.Published, Description: article.Description, Image: article.Image, Content: article.Content, AuthorUrl: article.AuthorUrl, Author: article.Author}
 
-		file, err := json.MarshalIndent(json_article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/coingabbar.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/coingabbar.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/coingabbar.com/%s.md", article.Title)
+		utils.Observe(article)
 
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, json_article)
 	}
+
+	utils.Finish()
 }
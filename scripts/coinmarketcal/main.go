@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -46,28 +42,6 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	date: "%s"
-	source: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Date, page.Source, page.Description)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	var page Page
@@ -106,76 +80,29 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/coinmarketcal.com/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/coinmarketcal.com/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/coinmarketcal.com/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/coinmarketcal.com/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToMd(*createdMd, page)
-
-		writeToCsv(*createdCsv, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coinmarketcal.com", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "coinmarketcal.com",
+		Path:     utils.NestedPath,
+		Body:     "description",
+		Coverage: map[string]float64{"title": 1, "link": 1, "date": 0.9, "description": 0.9},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":       "title",
+		"description": "#description",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -187,6 +114,7 @@ func main() {
 	})
 
 	c.Visit("https://coinmarketcal.com/")
+	utils.Source("https://coinmarketcal.com/")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -207,6 +135,12 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
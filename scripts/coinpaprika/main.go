@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -55,31 +52,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -94,6 +66,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		title := x.ChildText("title")
 		link := x.ChildText("link")
 		guid := x.ChildText("guid")
@@ -106,10 +82,7 @@ func getRssArticles() []Article {
 		desc, _ := r.Parse(strings.NewReader(x.ChildText("description")), link)
 		cont, _ := r.Parse(strings.NewReader(x.ChildText("content:encoded")), link)
 
-		content := cont.TextContent
-		content = strings.Replace(content, "\n", "", -1)
-		content = strings.Replace(content, "\t", "", -1)
-		content = strings.Replace(content, "\"", "'", -1)
+		content := utils.HTMLMarkdown(cont.Content, link)
 
 		description := desc.TextContent
 		description = strings.Replace(description, "\n", "", -1)
@@ -129,57 +102,17 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/coinpaprika.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "coinpaprika.com",
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "description": 0.9, "content": 0.95},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/coinpaprika.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/coinpaprika.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/coinpaprika.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+		utils.Observe(article)
 
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
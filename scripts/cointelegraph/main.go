@@ -1,13 +1,10 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -60,31 +57,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func getRssArticles() []Article {
 	var content string
 
@@ -92,6 +64,10 @@ func getRssArticles() []Article {
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".post-content",
+	})
+
 	c.SetRequestTimeout(time.Minute)
 
 	url, _ := url.Parse("socks5://138.68.16.30:13469")
@@ -113,13 +89,14 @@ func getRssArticles() []Article {
 	})
 
 	c.OnHTML(".post-content", func(h *colly.HTMLElement) {
-		content = h.Text
-		content = strings.Replace(content, "\n", "", -1)
-		content = strings.Replace(content, "\t", "", -1)
-		content = strings.Replace(content, "\"", "'", -1)
+		content = utils.ElementMarkdown(h)
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		guid := x.ChildText("guid")
 		title := x.ChildText("title")
 		link := x.ChildText("link")
@@ -153,57 +130,18 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/cointelegraph.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:       "cointelegraph.com",
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/cointelegraph.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/cointelegraph.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/cointelegraph.com/%s.md", article.Title)
+		utils.Observe(article)
 
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToMd(*createdMd, article)
-
-			writeToCsv(*createdCsv, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
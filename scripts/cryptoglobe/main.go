@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 
@@ -58,13 +55,17 @@ func getArticleDescription(link string) string {
 
 	c := colly.NewCollector(colly.MaxDepth(0))
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".article-body",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
 
 	c.OnHTML(".article-body", func(h *colly.HTMLElement) {
 		h.ForEach("p", func(i int, h *colly.HTMLElement) {
-			paragraphs = append(paragraphs, h.Text)
+			paragraphs = append(paragraphs, utils.ElementMarkdown(h))
 		})
 	})
 
@@ -72,32 +73,14 @@ func getArticleDescription(link string) string {
 
 	paragraphs = paragraphs[:len(paragraphs)-1]
 
-	return strings.Join(paragraphs, "")
-}
-
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
+	content := make([]string, 0)
+	for _, paragraph := range paragraphs {
+		if paragraph != "" {
+			content = append(content, paragraph)
+		}
+	}
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	category: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Category, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
+	return strings.Join(content, "\n\n")
 }
 
 func getRssArticles() []Article {
@@ -110,6 +93,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		link := x.ChildText("link")
 		title := x.ChildText("title")
 		published := x.ChildText("pubDate")
@@ -130,57 +117,18 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/cryptoglobe.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:       "cryptoglobe.com",
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
+		utils.Observe(article)
 
-		filepathJson := fmt.Sprintf("../../output/cryptoglobe.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/cryptoglobe.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/cryptoglobe.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -3,13 +3,10 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -154,31 +151,6 @@ func extractStringFromLimit(content io.Reader, startString string, endString str
 	return string(res)
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
@@ -196,6 +168,10 @@ func getRssArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 
 		article.Id = x.ChildText("guid")
@@ -208,7 +184,7 @@ func getRssArticles() []Article {
 		r := readability.New()
 
 		cont, _ := r.Parse(strings.NewReader(x.ChildText("content:encoded")), article.Link)
-		article.Content = cont.TextContent
+		article.Content = utils.HTMLMarkdown(cont.Content, article.Link)
 
 		reader := strings.NewReader(x.ChildText("content:encoded"))
 		div := extractStringFromLimit(reader, "<div>", "</div>")
@@ -221,10 +197,6 @@ func getRssArticles() []Article {
 
 		article.Image = strings.TrimSuffix(src, "\"")
 
-		article.Content = strings.Replace(article.Content, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
-
 		article.Description = strings.Replace(article.Description, "\n", "", -1)
 		article.Description = strings.Replace(article.Description, "\t", "", -1)
 		article.Description = strings.Replace(article.Description, "\"", "'", -1)
@@ -240,57 +212,17 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/en.cryptonomist.ch", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "en.cryptonomist.ch",
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.95},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/en.cryptonomist.ch/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/en.cryptonomist.ch/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/en.cryptonomist.ch/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
+		utils.Observe(article)
 
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -52,23 +48,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getArticles(c *colly.Collector) []Article {
 	articles := make([]Article, 0)
 	links := make([]string, 0)
@@ -94,6 +73,10 @@ func getArticles(c *colly.Collector) []Article {
 	})
 
 	c.OnHTML("article", func(h *colly.HTMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		for _, link := range links {
 			if link == h.ChildAttr("a", "href") {
 				article := getArticle(c, link)
@@ -116,6 +99,7 @@ func getArticles(c *colly.Collector) []Article {
 	})
 
 	c.Visit("https://cryptoslate.com/alpha-home/")
+	utils.Source("https://cryptoslate.com/alpha-home/")
 
 	return articles
 }
@@ -142,32 +126,23 @@ func getArticle(c *colly.Collector, link string) Article {
 
 	c.OnHTML("#csjump-introduction", func(h *colly.HTMLElement) {
 		if content == "" {
-			content = h.Text
+			content = utils.ElementMarkdown(h)
 			article.Content = content
-			article.Content = strings.Replace(content, "\n", "", -1)
-			article.Content = strings.Replace(content, "\t", "", -1)
-			article.Content = strings.Replace(content, "\"", "'", -1)
 		}
 	})
 
 	c.OnHTML("article > p", func(h *colly.HTMLElement) {
 		if content == "" {
-			content = h.Text
+			content = utils.ElementMarkdown(h)
 			article.Content = content
-			article.Content = strings.Replace(content, "\n", "", -1)
-			article.Content = strings.Replace(content, "\t", "", -1)
-			article.Content = strings.Replace(content, "\"", "'", -1)
 		}
 	})
 
 	c.OnHTML(".cs-alpha-paywall-teaser", func(h *colly.HTMLElement) {
 		h.ForEach("p", func(i int, h *colly.HTMLElement) {
 			if i == 0 && content == "" {
-				content = h.Text
+				content = utils.ElementMarkdown(h)
 				article.Content = content
-				article.Content = strings.Replace(content, "\n", "", -1)
-				article.Content = strings.Replace(content, "\t", "", -1)
-				article.Content = strings.Replace(content, "\"", "'", -1)
 			}
 		})
 	})
@@ -209,76 +184,31 @@ func getArticle(c *colly.Collector, link string) Article {
 	return article
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/cryptoslate.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "cryptoslate.com",
+		Coverage: map[string]float64{"title": 1, "link": 1, "author": 0.8, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
 	articles := getArticles(c)
 
 	for _, article := range articles {
+		if utils.SourceDone() {
+			break
+		}
+
 		if article.Title == "" {
 			continue
 		}
 
 		fmt.Printf("Downloaded: %s\n", article.Title)
 
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filename, _ := filenamify.FilenamifyV2(article.Title)
-
-		filepathJson := fmt.Sprintf("../../output/cryptoslate.com/%s.json", filename)
-		filepathCsv := fmt.Sprintf("../../output/cryptoslate.com/%s.csv", filename)
-		filepathMd := fmt.Sprintf("../../output/cryptoslate.com/%s.md", filename)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+		utils.Observe(article)
 
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
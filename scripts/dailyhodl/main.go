@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -59,37 +56,15 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	category: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Category, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRssArticles() []Article {
 	articles := make([]Article, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".content-inner",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -107,11 +82,15 @@ func getRssArticles() []Article {
 
 	c.OnHTML(".content-inner", func(h *colly.HTMLElement) {
 		h.ForEach("p", func(i int, h *colly.HTMLElement) {
-			paragraphs = append(paragraphs, h.Text)
+			paragraphs = append(paragraphs, utils.ElementMarkdown(h))
 		})
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		r := readability.New()
 
 		link := x.ChildText("link")
@@ -134,11 +113,14 @@ func getRssArticles() []Article {
 
 		x.Request.Visit(link)
 
-		article := Article{Link: link, Description: d.TextContent, Title: title, Published: published, Image: img, Id: pid, Author: author, Category: category, Content: strings.Join(paragraphs[:len(paragraphs)-1], "")}
+		content := make([]string, 0)
+		for _, paragraph := range paragraphs[:len(paragraphs)-1] {
+			if paragraph != "" {
+				content = append(content, paragraph)
+			}
+		}
 
-		article.Content = strings.Replace(article.Content, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		article := Article{Link: link, Description: d.TextContent, Title: title, Published: published, Image: img, Id: pid, Author: author, Category: category, Content: strings.Join(content, "\n\n")}
 
 		paragraphs = paragraphs[:0]
 
@@ -152,57 +134,18 @@ func getRssArticles() []Article {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/dailyhodl.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:       "dailyhodl.com",
+		Separators: map[string]string{"category": ", "},
+		Coverage:   map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.9},
+	})
 
 	rssArticles := getRssArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
+		utils.Observe(article)
 
-		filepathJson := fmt.Sprintf("../../output/dailyhodl.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/dailyhodl.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/dailyhodl.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
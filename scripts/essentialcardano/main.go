@@ -1,17 +1,14 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -44,27 +41,6 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	image: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Image, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	var page Page
@@ -80,13 +56,12 @@ func scrapePage(c *colly.Collector, href string) {
 	c.OnHTML("article", func(h *colly.HTMLElement) {
 		r := readability.New()
 
-		title := h.ChildText("h1")
+		article := h.DOM.Clone()
+		article.Find("h1").Remove()
 
-		cont, _ := r.Parse(strings.NewReader(h.Text), page.Link)
-		page.Content = strings.Replace(cont.TextContent, title, "", -1)
-		page.Content = strings.Replace(page.Content, "\n", "", -1)
-		page.Content = strings.Replace(page.Content, "\t", "", -1)
-		page.Content = strings.Replace(page.Content, "\"", "'", -1)
+		body, _ := goquery.OuterHtml(article)
+		cont, _ := r.Parse(strings.NewReader(body), page.Link)
+		page.Content = utils.HTMLMarkdown(cont.Content, page.Link)
 	})
 
 	c.OnHTML("span > img", func(h *colly.HTMLElement) {
@@ -101,76 +76,28 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/essentialcardano.io/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/essentialcardano.io/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/essentialcardano.io/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/essentialcardano.io/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/essentialcardano.io", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "essentialcardano.io",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   "h1",
+		"content": "article",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -186,6 +113,7 @@ func main() {
 	})
 
 	c.Visit("https://essentialcardano.io/")
+	utils.Source("https://essentialcardano.io/")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -206,7 +134,13 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		// print(link + "\n")
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -40,26 +36,6 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	var page Page
@@ -72,11 +48,7 @@ func scrapePage(c *colly.Collector, href string) {
 
 	c.OnHTML("div", func(h *colly.HTMLElement) {
 		if h.Attr("classname") == "tutorial" {
-			page.Content = h.Text
-			page.Content = strings.Replace(page.Content, "  ", "", 1)
-			page.Content = strings.Replace(page.Content, "\n", "", -1)
-			page.Content = strings.Replace(page.Content, "\t", "", -1)
-			page.Content = strings.Replace(page.Content, "\"", "", -1)
+			page.Content = utils.ElementMarkdown(h)
 		}
 	})
 
@@ -88,70 +60,17 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/monero.how/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
+	utils.Observe(page)
 
-	filepathJson := fmt.Sprintf("../../output/monero.how/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/monero.how/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/monero.how/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/monero.how", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "monero.how",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
@@ -173,6 +92,7 @@ func main() {
 	})
 
 	c.Visit("https://www.monero.how/monero-how-tutorials")
+	utils.Source("https://www.monero.how/monero-how-tutorials")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -193,6 +113,12 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
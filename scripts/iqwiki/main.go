@@ -1,12 +1,6 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
-	"log"
-	"os"
-	"regexp"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -58,32 +52,6 @@ func (page WikiPage) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, page WikiPage) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, page WikiPage) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	categories: "%s"
-	tags: "%s"
-	ipfs: "%s"
-	tx_hash: "%s"
-	published: "%s"
-	author: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Categories, page.Tags, page.IPFS, page.TxHash, page.Published, page.Author, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	var date string = ""
@@ -135,12 +103,7 @@ func scrapePage(c *colly.Collector, href string) {
 	})
 
 	c.OnHTML(".markdown_markdownBody__i1xqq", func(h *colly.HTMLElement) {
-		pattern := regexp.MustCompile(`.css-(.*?)}`)
-
-		page.Content = pattern.ReplaceAllString(h.ChildText("p"), "")
-		page.Content = strings.Replace(page.Content, "\n", "", -1)
-		page.Content = strings.Replace(page.Content, "\t", "", -1)
-		page.Content = strings.Replace(page.Content, "\"", "'", -1)
+		page.Content = utils.ElementMarkdown(h)
 	})
 
 	c.OnHTML("td > p", func(h *colly.HTMLElement) {
@@ -160,16 +123,6 @@ func scrapePage(c *colly.Collector, href string) {
 		return
 	}
 
-	if err := os.Mkdir(fmt.Sprintf("../../output/iqwiki.com/%s", page.Title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + page.Title + "\n")
-			return
-
-		}
-	}
-
 	savePage(page)
 
 	print("Downloaded: " + page.Title + "\n")
@@ -177,62 +130,25 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page WikiPage) {
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/iqwiki.com/%s/article.json", page.Title)
-	filepathCsv := fmt.Sprintf("../../output/iqwiki.com/%s/article.csv", page.Title)
-	filepathMd := fmt.Sprintf("../../output/iqwiki.com/%s/article.md", page.Title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/iqwiki.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "iqwiki.com",
+		Type:     "page",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.8, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".markdown_markdownBody__i1xqq",
+	})
+
 	wikis := make([]string, 0)
 
 	c.OnHTML("a", func(h *colly.HTMLElement) {
@@ -254,4 +170,6 @@ func main() {
 	for _, link := range wikis {
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
@@ -1,18 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"regexp"
 	"scripts/utils"
 	"strings"
 	"time"
 
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -42,29 +37,8 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	---
-	%s`,
-		page.Title, page.Link, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
-	pattern := regexp.MustCompile(`.css-(.*?)}`)
 
 	var page Page
 
@@ -77,13 +51,9 @@ func scrapePage(c *colly.Collector, href string) {
 	c.OnHTML(".css-lgbo0i", func(h *colly.HTMLElement) {
 		r := readability.New()
 
-		cont, _ := r.Parse(strings.NewReader(h.Text), page.Link)
-		content := pattern.ReplaceAllString(cont.TextContent, "")
-		content = strings.Replace(content, "\n", "", -1)
-		content = strings.Replace(content, "\t", "", -1)
-		content = strings.Replace(content, "\"", "'", -1)
-		content = strings.Replace(content, "@media screen and (min-width: 640px){}@media screen and (min-width: 1228px){}", "", -1)
-		page.Content = content
+		body, _ := h.DOM.Html()
+		cont, _ := r.Parse(strings.NewReader(body), page.Link)
+		page.Content = utils.HTMLMarkdown(cont.Content, page.Link)
 	})
 
 	c.Visit(page.Link)
@@ -94,76 +64,28 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/kernelcommunity.com/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/kernelcommunity.com/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/kernelcommunity.com/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/kernelcommunity.com/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/kernelcommunity.com", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "kernelcommunity.com",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "content": 0.9},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   "title",
+		"content": ".css-lgbo0i",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -175,6 +97,7 @@ func main() {
 	})
 
 	c.Visit("https://www.kernel.community/en/learn/")
+	utils.Source("https://www.kernel.community/en/learn/")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -195,6 +118,12 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
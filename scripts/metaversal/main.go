@@ -1,17 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -56,23 +52,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	description: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Description, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
 func getRSSArticles() []Article {
 	articles := make([]Article, 0)
 	c := colly.NewCollector(colly.MaxDepth(0))
@@ -91,6 +70,10 @@ func getRSSArticles() []Article {
 	})
 
 	c.OnXML("rss/channel/item", func(x *colly.XMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 		article.Id = x.ChildText("guid")
 		article.Title = x.ChildText("title")
@@ -106,9 +89,7 @@ func getRSSArticles() []Article {
 
 		article.Title = strings.Replace(article.Title, "🫨", "", -1)
 
-		article.Content = strings.Replace(cont.TextContent, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		article.Content = utils.HTMLMarkdown(cont.Content, article.Link)
 
 		article.Description = strings.Replace(article.Description, "\n", "", -1)
 		article.Description = strings.Replace(article.Description, "\t", "", -1)
@@ -124,74 +105,19 @@ func getRSSArticles() []Article {
 	return articles
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/metaversal.banklesshq.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "metaversal.banklesshq.com",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "author": 0.9, "description": 0.9, "content": 0.95},
+	})
 
 	rssArticles := getRSSArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-
-		filename, _ := filenamify.FilenamifyV2(article.Title)
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if err := os.Mkdir(fmt.Sprintf("../../output/metaversal.banklesshq.com/%s", filename), os.ModePerm); err != nil {
-			if !strings.Contains(err.Error(), "file exists") {
-				log.Fatal(err)
-			}
-		}
+		utils.Observe(article)
 
-		filepathJson := fmt.Sprintf("../../output/metaversal.banklesshq.com/%s/article.json", filename)
-		filepathCsv := fmt.Sprintf("../../output/metaversal.banklesshq.com/%s/article.csv", filename)
-		filepathMd := fmt.Sprintf("../../output/metaversal.banklesshq.com/%s/article.md", filename)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
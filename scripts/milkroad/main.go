@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
@@ -49,22 +46,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getArticleLinks(c *colly.Collector) []string {
 	articles := make([]string, 0)
 
@@ -83,6 +64,7 @@ func getArticleLinks(c *colly.Collector) []string {
 	})
 
 	c.Visit("https://milkroad.com/daily/")
+	utils.Source("https://milkroad.com/daily/")
 
 	return articles
 }
@@ -99,11 +81,9 @@ func getArticle(c *colly.Collector, link string) Article {
 
 		r := readability.New()
 
-		cont, _ := r.Parse(strings.NewReader(h.Text), link)
-		article.Content = cont.TextContent
-		article.Content = strings.Replace(article.Content, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		body, _ := h.DOM.Html()
+		cont, _ := r.Parse(strings.NewReader(body), link)
+		article.Content = utils.HTMLMarkdown(cont.Content, link)
 	})
 
 	c.OnHTML(".single-date", func(h *colly.HTMLElement) {
@@ -121,76 +101,33 @@ func getArticle(c *colly.Collector, link string) Article {
 	return article
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/milkroad.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "milkroad.com",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.9, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   ".entry-title",
+		"content": ".beehiiv__body",
+	})
+
 	links := getArticleLinks(c)
 
 	for _, link := range links {
-		article := getArticle(c, link)
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
+		if utils.SourceDone() {
+			break
 		}
 
-		if err := os.Mkdir(fmt.Sprintf("../../output/milkroad.com/%s", article.Title), os.ModePerm); err != nil {
-			if !strings.Contains(err.Error(), "file exists") {
-				log.Fatal(err)
-			}
-		}
-
-		filepathJson := fmt.Sprintf("../../output/milkroad.com/%s/article.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/milkroad.com/%s/article.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/milkroad.com/%s/article.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
+		article := getArticle(c, link)
 
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
+		utils.Observe(article)
 
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
 
+	utils.Finish()
 }
@@ -1,19 +1,15 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 )
 
 var links [10]string = [10]string{"https://www.pointer.gg/tutorials/Setting-Up-Your-Wallet/6e23e8e8-6760-45fa-8fb3-330400ac03ac", "https://www.pointer.gg/tutorials/solid-solidity/a7ec3eff-fc59-481d-bcb2-1224b3e9c0f7", "https://www.pointer.gg/tutorials/thirdweb-nft-lootbox/feda78d2-d35c-4b77-a1d3-182aa16070d9", "https://www.pointer.gg/tutorials/create-a-web3-forum-with-polygon/1cb8f005-08f4-48a2-9d82-cd963e16f7f1", "https://www.pointer.gg/tutorials/solana-pay-irl-payments/944eba7e-82c6-4527-b55c-5411cdf63b23", "https://www.pointer.gg/tutorials/build-a-dex-with-stacks/56abb3a4-05c1-4608-b096-f82189e9f759", "https://www.pointer.gg/tutorials/solana-anchor/6f9afc45-309d-4f3e-abfc-aa40dd09fd0a", "https://www.pointer.gg/tutorials/polygon-amm/c725cd07-447b-46b3-b544-81516b172c5f", "https://www.pointer.gg/tutorials/polygon-amm2/65775edd-eb14-4f44-8b0b-38778c51fc5d", "https://www.pointer.gg/tutorials/solana-nft-collection/0c289046-8e7a-42fe-9d38-9f7ef52c0d7d"}
@@ -59,89 +55,23 @@ func (page PageData) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, page PageData) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	tutorial_id: "%s"
-	---
-	%s`,
-		page.Id, page.Title, page.Link, page.Published, page.TutorialId, page.Content)
+// filename names the page after its title and tutorial id, as pages of
+// different tutorials often share a title. The title is shortened so that
+// the id always fits in the slug.
+func filename(page PageData) string {
+	title := utils.Slug(page.Title)
 
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
+	if max := utils.MaxSlugLength - len(page.TutorialId) - 1; len(title) > max {
+		title = strings.TrimSuffix(title[:max], "-")
+	}
 
-func writeToCsv(csvFile os.File, page PageData) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
+	return title + "-" + page.TutorialId
 }
 
 func savePage(page PageData) {
-	title, _ := filenamify.FilenamifyV2(page.Title + "-" + page.TutorialId)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/pointer.gg/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/pointer.gg/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/pointer.gg/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/pointer.gg/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
+	utils.Observe(page)
 
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{
-			"category":  "categories",
-			"published": "created_at",
-		}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(filename(page), page)
 }
 
 func scrapePage(link string) {
@@ -170,16 +100,19 @@ func scrapePage(link string) {
 
 	json.Unmarshal(res_body, &init)
 
-	for _, publication := range init[0].TutorialPublications {
+	utils.Source(link)
+
+	for i, publication := range init[0].TutorialPublications {
+		if utils.LimitReached(i) {
+			break
+		}
+
 		r := readability.New()
 
 		cont, _ := r.Parse(strings.NewReader(publication.Content), fmt.Sprintf("https://www.pointer.gg/tutorials/%s/%s", slug, publication.Id))
 
 		publication.Link = fmt.Sprintf("https://www.pointer.gg/tutorials/%s/%s", slug, publication.Id)
-		publication.Content = cont.TextContent
-		publication.Content = strings.Replace(publication.Content, "\n", "", -1)
-		publication.Content = strings.Replace(publication.Content, "\t", "", -1)
-		publication.Content = strings.Replace(publication.Content, "\"", "'", -1)
+		publication.Content = utils.HTMLMarkdown(cont.Content, publication.Link)
 
 		fmt.Printf("Downloaded: %s\n", publication.Title)
 
@@ -188,13 +121,20 @@ func scrapePage(link string) {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/pointer.gg", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "pointer.gg",
+		Type: "page",
+		Path: utils.NestedPath,
+		Renames: map[string]string{
+			"category":  "categories",
+			"published": "created_at",
+		},
+		Coverage: map[string]float64{"title": 1, "link": 1, "tutorial_id": 1, "content": 0.95},
+	})
 
 	for _, link := range links {
 		scrapePage(link)
 	}
+
+	utils.Finish()
 }
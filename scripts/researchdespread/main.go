@@ -1,17 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
 	"github.com/cixtor/readability"
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -53,23 +48,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getArticleLinks(c *colly.Collector) []string {
 	articles := make([]string, 0)
 
@@ -94,6 +72,7 @@ func getArticleLinks(c *colly.Collector) []string {
 	})
 
 	c.Visit("https://research.despread.io/")
+	utils.Source("https://research.despread.io/")
 
 	return articles
 }
@@ -107,8 +86,7 @@ func getArticle(c *colly.Collector, link string) Article {
 	})
 
 	c.OnHTML(".post-hero__title", func(h *colly.HTMLElement) {
-		title, _ := filenamify.FilenamifyV2(h.Text)
-		article.Title = title
+		article.Title = h.Text
 	})
 
 	c.OnHTML(".post-info__author", func(h *colly.HTMLElement) {
@@ -122,11 +100,9 @@ func getArticle(c *colly.Collector, link string) Article {
 	c.OnHTML("article", func(h *colly.HTMLElement) {
 		r := readability.New()
 
-		content, _ := r.Parse(strings.NewReader(h.Text), link)
-		article.Content = content.TextContent
-		article.Content = strings.Replace(article.Content, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		body, _ := h.DOM.Html()
+		content, _ := r.Parse(strings.NewReader(body), link)
+		article.Content = utils.HTMLMarkdown(content.Content, link)
 	})
 
 	c.Visit(link)
@@ -136,74 +112,36 @@ func getArticle(c *colly.Collector, link string) Article {
 	return article
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/research.despread.io", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "research.despread.io",
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.9, "author": 0.8, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   ".post-hero__title",
+		"content": "article",
+	})
+
 	links := getArticleLinks(c)
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		article := getArticle(c, link)
 
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
+		utils.Observe(article)
 
 		if article.Title == "" {
 			return
 		}
 
-		filepathJson := fmt.Sprintf("../../output/research.despread.io/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/research.despread.io/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/research.despread.io/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
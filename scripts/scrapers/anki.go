@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"scripts/utils"
+	"sort"
+	"strings"
+)
+
+// mergedDeck names the deck of the terms of every glossary.
+const mergedDeck = "Crypto glossary"
+
+func init() {
+	registerCommand("anki", "build Anki flashcard decks of the scraped glossary terms", runAnki)
+}
+
+func runAnki(args []string) error {
+	flags := flag.NewFlagSet("anki", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", strings.Join(glossarySites, ","), "make cards of the glossary terms of the comma separated `SITES`")
+	merge := flags.Bool("merge", false, "write one deck of every glossary instead of one deck per site")
+	dir := flags.String("dir", "", "write decks to `DIR` (default <output>/anki)")
+
+	flags.Parse(args)
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "anki")
+	}
+
+	entries, err := utils.LoadCorpus(*output, list(*sites))
+	if err != nil {
+		return err
+	}
+
+	decks := map[string]*utils.Deck{}
+	names := make([]string, 0)
+
+	for _, entry := range entries {
+		term := entry.Title()
+		if entry.Type != "glossary" || term == "" {
+			continue
+		}
+
+		name := entry.Site
+		if *merge {
+			name = mergedDeck
+		}
+
+		if decks[name] == nil {
+			decks[name] = &utils.Deck{Name: mergedDeck + "::" + entry.Site}
+			if *merge {
+				decks[name].Name = mergedDeck
+			}
+			names = append(names, name)
+		}
+
+		decks[name].Cards = append(decks[name].Cards, glossaryCard(entry))
+	}
+
+	if err := os.MkdirAll(*dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		cards := decks[name].Cards
+		sort.SliceStable(cards, func(i, j int) bool {
+			return strings.ToLower(cards[i].Term) < strings.ToLower(cards[j].Term)
+		})
+
+		path := filepath.Join(*dir, utils.Slug(name)+".apkg")
+		if err := utils.WriteApkg(path, *decks[name]); err != nil {
+			return fmt.Errorf("unable to write %s: %v", path, err)
+		}
+
+		fmt.Printf("Wrote %s, %d cards\n", path, len(decks[name].Cards))
+	}
+
+	return nil
+}
+
+// glossaryCard returns the card of the glossary term of entry, tagged with
+// its site and difficulty.
+func glossaryCard(entry utils.Entry) utils.Card {
+	card := utils.Card{
+		Key:        entry.Site + "\n" + strings.ToLower(entry.Title()),
+		Term:       entry.Title(),
+		Definition: utils.CleanHTML(entry.Content()),
+		Source:     entry.Site,
+		Tags:       []string{"glossary", utils.Slug(entry.Site)},
+	}
+
+	if summary := entry.Summary(); summary != "" {
+		card.Definition = "<p><b>" + html.EscapeString(summary) + "</b></p>\n" + card.Definition
+	}
+
+	if link := entry.Link(); link != "" {
+		card.Source = `<a href="` + html.EscapeString(link) + `">` + entry.Site + `</a>`
+	}
+
+	// academy.binance.com saves the difficulty as {level, label, slug}
+	difficulty := entry.Text("difficulty")
+	if object, ok := entry.Fields["difficulty"].(map[string]interface{}); ok {
+		difficulty, _ = object["label"].(string)
+	}
+
+	if tag := utils.Slug(difficulty); tag != "" {
+		card.Tags = append(card.Tags, "difficulty::"+tag)
+	}
+
+	return card
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scripts/utils"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand("archive", "take a compressed snapshot of the output, or restore one with archive restore", runArchive)
+}
+
+func runArchive(args []string) error {
+	if len(args) > 0 && args[0] == "restore" {
+		return runRestore(args[1:])
+	}
+
+	flags := flag.NewFlagSet("archive", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "archive documents from `DIR`")
+	sites := flags.String("site", "", "archive the comma separated `SITES` only")
+	dir := flags.String("dir", "", "write the archive to `DIR` (default <output>/archives)")
+	since := flags.String("since", "", "only archive the changes since the snapshot `ARCHIVE`, e.g. the last full one")
+
+	flags.Parse(args)
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "archives")
+	}
+
+	var base *utils.Manifest
+	if *since != "" {
+		manifest, err := utils.ReadManifest(*since)
+		if err != nil {
+			return err
+		}
+		base = &manifest
+	}
+
+	name := allSites
+	if len(list(*sites)) > 0 {
+		name = utils.Slug(strings.Join(list(*sites), "-"))
+	}
+	name += "-" + time.Now().UTC().Format("20060102T150405Z")
+	if base != nil {
+		name += "-incremental"
+	}
+
+	if err := os.MkdirAll(*dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	path := filepath.Join(*dir, name+".tar.zst")
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := utils.WriteArchive(file, *output, name, list(*sites), base)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	fmt.Printf("Wrote %s: %d documents and %d files", path, len(manifest.Documents), len(manifest.Included))
+	if base != nil {
+		fmt.Printf(" changed since %s, %d deleted", base.Name, len(manifest.Deleted))
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("archive restore", flag.ExitOnError)
+
+	to := flags.String("to", utils.DefaultOutput(), "restore to `DIR`")
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: scrapers archive restore [flags] ARCHIVE...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Restores a full archive followed by the incremental archives taken after it, in order.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// check the chain of snapshots before touching the output
+	previous := ""
+	for i, path := range flags.Args() {
+		manifest, err := utils.ReadManifest(path)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 && manifest.Base != "" {
+			return fmt.Errorf("%s has the changes since %s, restore a full archive first", path, manifest.Base)
+		}
+
+		if i > 0 && manifest.Base == "" {
+			return fmt.Errorf("%s is a full archive, restore it first", path)
+		}
+
+		if i > 0 && manifest.Base != previous {
+			return fmt.Errorf("%s has the changes since %s, not since %s", path, manifest.Base, previous)
+		}
+
+		previous = manifest.Name
+	}
+
+	for _, path := range flags.Args() {
+		manifest, err := utils.RestoreArchive(path, *to)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored %s to %s: %d files, %d deleted\n", manifest.Name, *to, len(manifest.Included), len(manifest.Deleted))
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"scripts/utils"
+	"sort"
+	"strings"
+)
+
+// guideSites are the sites scraping multi-page guides, which books are
+// built of by default.
+var guideSites = []string{"coincashew.com", "pointer.gg", "kernelcommunity.com", "monero.how", "armantheparman.com"}
+
+// guideSections returns the section of the table of contents a page of a
+// site is listed under, for the sites mixing several guides.
+var guideSections = map[string]func(entry utils.Entry) string{
+	// pages of pointer.gg tutorials are at /tutorials/<tutorial>/<page>
+	"pointer.gg": func(entry utils.Entry) string {
+		link, err := url.Parse(entry.Link())
+		if err != nil {
+			return ""
+		}
+
+		parts := strings.Split(strings.Trim(link.Path, "/"), "/")
+		if len(parts) < 2 || parts[1] == "" {
+			return ""
+		}
+
+		title := strings.ReplaceAll(parts[1], "-", " ")
+
+		return strings.ToUpper(title[:1]) + title[1:]
+	},
+}
+
+func init() {
+	registerCommand("epub", "build EPUB books of the guides scraped from a site, to read offline", runEpub)
+}
+
+func runEpub(args []string) error {
+	flags := flag.NewFlagSet("epub", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", strings.Join(guideSites, ","), "build books of the comma separated `SITES`")
+	dir := flags.String("dir", "", "write books to `DIR` (default <output>/epub)")
+	images := flags.Bool("images", true, "download and embed the images of the pages")
+
+	flags.Parse(args)
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "epub")
+	}
+
+	entries, err := utils.LoadCorpus(*output, list(*sites))
+	if err != nil {
+		return err
+	}
+
+	pages := map[string][]utils.Entry{}
+	for _, entry := range entries {
+		pages[entry.Site] = append(pages[entry.Site], entry)
+	}
+
+	books := map[string]*utils.Book{}
+	for _, name := range list(*sites) {
+		books[name] = &utils.Book{Title: name, Source: "https://" + name + "/"}
+		if *images {
+			books[name].Fetch = utils.FetchImage
+		}
+	}
+
+	for _, name := range list(*sites) {
+		for _, entry := range guideOrder(pages[name]) {
+			addChapter(books[name], entry)
+		}
+	}
+
+	if err := os.MkdirAll(*dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, name := range list(*sites) {
+		book := books[name]
+		if len(book.Chapters) == 0 {
+			fmt.Printf("Skipped %s, no pages were scraped\n", name)
+			continue
+		}
+
+		groupSections(book.Chapters)
+
+		path := filepath.Join(*dir, name+".epub")
+		if err := writeBook(path, *book); err != nil {
+			return fmt.Errorf("unable to write %s: %v", path, err)
+		}
+
+		fmt.Printf("Wrote %s, %d pages\n", path, len(book.Chapters))
+	}
+
+	return nil
+}
+
+// addChapter adds the page of entry to book.
+func addChapter(book *utils.Book, entry utils.Entry) {
+	chapter := utils.Chapter{Title: entry.Title(), Link: entry.Link(), Content: entry.Content()}
+	if chapter.Title == "" {
+		chapter.Title = entry.Id
+	}
+
+	if section := guideSections[entry.Site]; section != nil {
+		chapter.Section = section(entry)
+	}
+
+	if entry.Saved.After(book.Scraped) {
+		book.Scraped = entry.Saved
+	}
+
+	book.Chapters = append(book.Chapters, chapter)
+}
+
+// guideLink matches the destinations of the links of Markdown and HTML
+// content.
+var guideLink = regexp.MustCompile(`\]\(<?([^)\s>]+)|href="([^"]+)"`)
+
+// guideOrder returns the pages of a guide in the order the guide links
+// them: depth first from its first page, following the links of every page
+// in the order they appear, so that pages scraped later are read where the
+// guide links them rather than at the end. The first page is the one with
+// the shortest link, the index of the guide, and the pages it does not
+// reach follow from the shortest of them in the same way.
+func guideOrder(pages []utils.Entry) []utils.Entry {
+	byLink := map[string]int{}
+	for i, page := range pages {
+		byLink[guideKey(page.Link())] = i
+	}
+
+	links := make([][]int, len(pages))
+
+	for i, page := range pages {
+		base, err := url.Parse(page.Link())
+		if err != nil {
+			continue
+		}
+
+		for _, match := range guideLink.FindAllStringSubmatch(page.Content(), -1) {
+			destination, err := base.Parse(match[1] + match[2])
+			if err != nil {
+				continue
+			}
+
+			if j, ok := byLink[guideKey(destination.String())]; ok && j != i {
+				links[i] = append(links[i], j)
+			}
+		}
+	}
+
+	starts := make([]int, 0)
+	for i := range pages {
+		starts = append(starts, i)
+	}
+	sort.SliceStable(starts, func(a, b int) bool {
+		i, j := starts[a], starts[b]
+		if len(pages[i].Link()) != len(pages[j].Link()) {
+			return len(pages[i].Link()) < len(pages[j].Link())
+		}
+		return pages[i].Link() < pages[j].Link()
+	})
+
+	ordered := make([]utils.Entry, 0, len(pages))
+	visited := make([]bool, len(pages))
+
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		ordered = append(ordered, pages[i])
+
+		for _, j := range links[i] {
+			visit(j)
+		}
+	}
+
+	for _, i := range starts {
+		visit(i)
+	}
+
+	return ordered
+}
+
+// guideKey returns link without its fragment and trailing slash, as pages
+// link each other.
+func guideKey(link string) string {
+	link, _, _ = strings.Cut(link, "#")
+
+	return strings.TrimSuffix(link, "/")
+}
+
+// groupSections moves the chapters of every section next to the first one,
+// keeping the order of sections and of the chapters within them.
+func groupSections(chapters []utils.Chapter) {
+	first := map[string]int{}
+	for i, chapter := range chapters {
+		if _, ok := first[chapter.Section]; !ok {
+			first[chapter.Section] = i
+		}
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return first[chapters[i].Section] < first[chapters[j].Section]
+	})
+}
+
+func writeBook(path string, book utils.Book) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := book.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"scripts/utils"
+	"testing"
+	"time"
+)
+
+func TestGuideOrderFollowsLinks(t *testing.T) {
+	page := func(link string, saved int, content string) utils.Entry {
+		return utils.Entry{Id: link, Saved: time.Unix(int64(saved), 0), Fields: map[string]interface{}{"link": link, "content": content}}
+	}
+
+	// the staking page was added to the guide after the others were scraped
+	pages := []utils.Entry{
+		page("https://guide.example/setup/", 2, "Next: [wallet](../wallet)"),
+		page("https://guide.example/", 1, "[Setup](/setup/) [Staking](staking#intro) <a href=\"/wallet\">Wallet</a>"),
+		page("https://guide.example/wallet", 3, "Back to [the guide](/)"),
+		page("https://guide.example/staking", 4, "[Setup](/setup/)"),
+		page("https://guide.example/unlinked", 5, ""),
+	}
+
+	want := []string{"https://guide.example/", "https://guide.example/setup/", "https://guide.example/wallet", "https://guide.example/staking", "https://guide.example/unlinked"}
+
+	ordered := guideOrder(pages)
+	for i, link := range want {
+		if i >= len(ordered) || ordered[i].Link() != link {
+			t.Fatalf("got page %d %v, want %s", i, ordered, link)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"scripts/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedFormats are the formats feeds are written in, by file extension.
+var feedFormats = map[string]func(feed utils.Feed) ([]byte, error){
+	"rss":  utils.Feed.RSS,
+	"atom": utils.Feed.Atom,
+}
+
+// allSites names the feed merging every site.
+const allSites = "all"
+
+func init() {
+	registerCommand("feed", "write RSS and Atom feeds of the scraped documents, or serve them", runFeed)
+}
+
+func runFeed(args []string) error {
+	flags := flag.NewFlagSet("feed", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", "", "write feeds of the comma separated `SITES` only")
+	categories := flags.String("category", "", "keep documents in one of the comma separated `CATEGORIES`")
+	tickers := flags.String("ticker", "", "keep documents mentioning one of the comma separated `TICKERS`, e.g. BTC,ETH")
+	keywords := flags.String("keyword", "", "keep documents containing one of the comma separated `KEYWORDS`")
+	formats := flags.String("format", "rss,atom", "write the comma separated `FORMATS`")
+	limit := flags.Int("limit", 50, "keep the newest `N` documents of every feed")
+	dir := flags.String("dir", "", "write feeds to `DIR` (default <output>/feeds)")
+	baseUrl := flags.String("base-url", "", "link feeds to where they are published at `URL`, e.g. https://example.com/feeds, required unless serving")
+	serve := flags.String("serve", "", "serve feeds at `ADDR`, e.g. :8080, instead of writing them")
+
+	flags.Parse(args)
+
+	filter := utils.FeedFilter{Categories: list(*categories), Tickers: list(*tickers), Keywords: list(*keywords)}
+
+	for _, format := range list(*formats) {
+		if feedFormats[format] == nil {
+			return fmt.Errorf("unknown format %q, expected rss or atom", format)
+		}
+	}
+
+	if *serve != "" {
+		return serveFeeds(*serve, *output, list(*sites), filter, *limit)
+	}
+
+	// RSS channels must link to a website, which the feed of all sites only
+	// has where the feeds are published
+	if *baseUrl == "" {
+		return fmt.Errorf("no --base-url to link the feeds to")
+	}
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "feeds")
+	}
+
+	entries, err := utils.LoadCorpus(*output, list(*sites))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	names := []string{allSites}
+	for _, entry := range entries {
+		if !contains(names, entry.Site) {
+			names = append(names, entry.Site)
+		}
+	}
+
+	for _, name := range names {
+		for _, format := range list(*formats) {
+			url := strings.TrimSuffix(*baseUrl, "/") + "/" + name + "." + format
+
+			body, err := feedFormats[format](buildFeed(entries, name, *limit, url, filter))
+			if err != nil {
+				return err
+			}
+
+			path := filepath.Join(*dir, name+"."+format)
+			if err := os.WriteFile(path, body, 0644); err != nil {
+				return err
+			}
+
+			fmt.Printf("Wrote %s\n", path)
+		}
+	}
+
+	return nil
+}
+
+// buildFeed returns the feed of the site name, or of all sites, with the
+// newest limit entries passing every filter. The feed of a site links to
+// the site.
+func buildFeed(entries []utils.Entry, name string, limit int, url string, filters ...utils.FeedFilter) utils.Feed {
+	feed := utils.Feed{Title: name, Url: url, Link: "https://" + name + "/"}
+	if name == allSites {
+		feed.Title = "All sites"
+		feed.Link = ""
+	}
+
+	for _, entry := range entries {
+		if limit > 0 && len(feed.Entries) >= limit {
+			break
+		}
+
+		if name != allSites && entry.Site != name {
+			continue
+		}
+
+		matches := true
+		for _, filter := range filters {
+			matches = matches && filter.Match(entry)
+		}
+
+		if matches {
+			feed.Entries = append(feed.Entries, entry)
+		}
+	}
+
+	return feed
+}
+
+// feedReload is how long served feeds are built from the same read of the
+// output directory.
+const feedReload = 5 * time.Minute
+
+// serveFeeds serves /<site>.rss, /<site>.atom and the feeds of all sites
+// at /all.rss and /all.atom, filtered by filter and by the category, ticker
+// and keyword query parameters.
+func serveFeeds(addr string, output string, sites []string, filter utils.FeedFilter, limit int) error {
+	var mutex sync.Mutex
+	var entries []utils.Entry
+	var loaded time.Time
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		format := strings.TrimPrefix(filepath.Ext(name), ".")
+		name = strings.TrimSuffix(name, "."+format)
+
+		encode := feedFormats[format]
+		if encode == nil || name == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		mutex.Lock()
+		if entries == nil || time.Since(loaded) > feedReload {
+			corpus, err := utils.LoadCorpus(output, sites)
+			if err != nil {
+				mutex.Unlock()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			entries, loaded = corpus, time.Now()
+		}
+		current := entries
+		mutex.Unlock()
+
+		query := r.URL.Query()
+		requested := utils.FeedFilter{
+			Categories: list(strings.Join(query["category"], ",")),
+			Tickers:    list(strings.Join(query["ticker"], ",")),
+			Keywords:   list(strings.Join(query["keyword"], ",")),
+		}
+
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+
+		feed := buildFeed(current, name, limit, scheme+"://"+r.Host+r.URL.RequestURI(), filter, requested)
+		if name != allSites && len(feed.Entries) == 0 && !hasSite(current, name) {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := encode(feed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/"+format+"+xml; charset=utf-8")
+		w.Write(body)
+	}
+
+	log.Printf("Serving feeds at %s, e.g. /all.rss?ticker=BTC", addr)
+
+	return http.ListenAndServe(addr, http.HandlerFunc(handler))
+}
+
+func hasSite(entries []utils.Entry, name string) bool {
+	for _, entry := range entries {
+		if entry.Site == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,3 @@
+module scripts/scrapers
+
+go 1.18
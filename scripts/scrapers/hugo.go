@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"scripts/utils"
+)
+
+func init() {
+	registerCommand("hugo", "write the scraped documents as Hugo page bundles, one section per site", runHugo)
+}
+
+func runHugo(args []string) error {
+	flags := flag.NewFlagSet("hugo", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", "", "write pages of the comma separated `SITES` only")
+	dir := flags.String("dir", "", "write to the Hugo site at `DIR`, under content (default <output>/hugo)")
+	draft := flags.Bool("draft", false, "mark the pages as drafts")
+	images := flags.Bool("images", true, "download the images of the documents into their bundles")
+
+	flags.Parse(args)
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "hugo")
+	}
+
+	entries, err := utils.LoadCorpus(*output, list(*sites))
+	if err != nil {
+		return err
+	}
+
+	bundles := utils.Bundles{Dir: *dir, Draft: *draft}
+	if *images {
+		bundles.Fetch = utils.FetchImage
+	}
+
+	sections := make([]string, 0)
+	for _, entry := range entries {
+		if !contains(sections, entry.Site) {
+			if err := bundles.WriteSection(entry.Site); err != nil {
+				return err
+			}
+			sections = append(sections, entry.Site)
+		}
+
+		if err := bundles.Write(entry); err != nil {
+			return fmt.Errorf("unable to write the bundle of %s: %v", entry.Path, err)
+		}
+	}
+
+	fmt.Printf("Wrote %d pages in %d sections to %s\n", len(entries), len(sections), filepath.Join(*dir, "content"))
+
+	return nil
+}
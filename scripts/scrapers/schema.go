@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"scripts/utils"
+	"strings"
+)
+
+func init() {
+	registerCommand("schema", "check the published JSON schemas against a release with schema check", runSchema)
+}
+
+func runSchema(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: scrapers schema check --released DIR")
+	}
+
+	flags := flag.NewFlagSet("schema check", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "check the schemas published in `DIR`")
+	released := flags.String("released", "", "compare with the schemas of the release in `DIR`, laid out like the output")
+
+	flags.Parse(args[1:])
+
+	if *released == "" {
+		return fmt.Errorf("--released is required")
+	}
+
+	changes, err := utils.CheckSchemas(*output, *released)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No breaking schema changes")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Printf("%s breaks the released schema without a new version:\n  %s\n", change.Path, strings.Join(change.Breaks, "\n  "))
+	}
+
+	return fmt.Errorf("%d schemas have breaking changes, bump the SchemaVersion of their sites", len(changes))
+}
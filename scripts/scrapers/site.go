@@ -0,0 +1,372 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"scripts/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed site
+var siteFiles embed.FS
+
+// siteTemplates are the pages of the reader site, each rendered inside
+// site/layout.html.
+var siteTemplates = map[string]*template.Template{}
+
+// maxSearchText is how much of the text of a document the search index
+// holds.
+const maxSearchText = 1000
+
+// otherLetter groups the glossary terms not starting with a letter.
+const otherLetter = "#"
+
+func init() {
+	registerCommand("site", "build a static website to read the scraped documents in a browser", runSite)
+
+	funcs := template.FuncMap{
+		"date": func(date time.Time) string {
+			return date.Format("2 Jan 2006")
+		},
+	}
+
+	for _, page := range []string{"index", "list", "article", "glossary", "tags", "search"} {
+		siteTemplates[page] = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(siteFiles, "site/layout.html", "site/"+page+".html"))
+	}
+}
+
+// sitePage is what every page of the site is rendered with.
+type sitePage struct {
+	// Root is the relative path from the page to the root of the site.
+	Root string
+	// Name is the title of the site.
+	Name string
+	// Title is the title of the page.
+	Title string
+	// Body is what the page template shows.
+	Body interface{}
+}
+
+// siteLink is a link to a page of the site, from its root.
+type siteLink struct {
+	Title   string
+	Href    string
+	Count   int
+	Date    time.Time
+	Summary string
+}
+
+// siteArticle is the page of a document.
+type siteArticle struct {
+	Entry   utils.Entry
+	Site    siteLink
+	Link    string
+	Authors []string
+	Tags    []siteLink
+	Content template.HTML
+}
+
+// siteGlossary is the A–Z index of glossary terms, or the page of one
+// letter when Letter is set.
+type siteGlossary struct {
+	Letter  string
+	Letters []siteLink
+	Terms   []siteLink
+}
+
+// searchItem is a document in the search index, with short keys to keep
+// the index small.
+type searchItem struct {
+	Title string `json:"t"`
+	Href  string `json:"u"`
+	Site  string `json:"s"`
+	Date  string `json:"d"`
+	Text  string `json:"x"`
+}
+
+func runSite(args []string) error {
+	if len(args) == 0 || args[0] != "build" {
+		return fmt.Errorf("usage: scrapers site build [flags]")
+	}
+
+	flags := flag.NewFlagSet("site build", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", "", "build pages of the comma separated `SITES` only")
+	dir := flags.String("dir", "", "write the site to `DIR` (default <output>/site)")
+	name := flags.String("title", "Scraped documents", "name the site `TITLE`")
+
+	flags.Parse(args[1:])
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "site")
+	}
+
+	entries, err := utils.LoadCorpus(*output, list(*sites))
+	if err != nil {
+		return err
+	}
+
+	builder := siteBuilder{dir: *dir, name: *name}
+	if err := builder.build(entries); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d pages to %s, open %s\n", builder.pages, *dir, filepath.Join(*dir, "index.html"))
+
+	return nil
+}
+
+// siteBuilder writes the pages of the site to dir.
+type siteBuilder struct {
+	dir   string
+	name  string
+	pages int
+}
+
+func (builder *siteBuilder) build(entries []utils.Entry) error {
+	sources := make([]siteLink, 0)
+	bySite := map[string][]siteLink{}
+	tags := map[string]*siteLink{}
+	byTag := map[string][]siteLink{}
+	letters := map[string][]siteLink{}
+	index := make([]searchItem, 0)
+
+	for _, entry := range entries {
+		title := entry.Title()
+		if title == "" {
+			title = entry.Id
+		}
+
+		link := siteLink{
+			Title:   title,
+			Href:    entry.Site + "/" + utils.Filename(title, entry.Id) + ".html",
+			Date:    entry.Date(),
+			Summary: entry.Summary(),
+		}
+		if link.Summary == "" {
+			link.Summary = utils.Excerpt(entry.Content(), 200)
+		}
+
+		if _, ok := bySite[entry.Site]; !ok {
+			sources = append(sources, siteLink{Title: entry.Site, Href: entry.Site + "/index.html"})
+		}
+		bySite[entry.Site] = append(bySite[entry.Site], link)
+
+		article := siteArticle{
+			Entry:   entry,
+			Site:    siteLink{Title: entry.Site, Href: entry.Site + "/index.html"},
+			Link:    entry.Link(),
+			Authors: entry.Authors(),
+			Content: template.HTML(utils.CleanHTML(entry.Content())),
+		}
+
+		// the same tag may be in several fields or spelled differently,
+		// e.g. DeFi and defi, and is linked once
+		tagged := map[string]bool{}
+
+		for _, category := range entry.Categories() {
+			slug := utils.Slug(category)
+			if slug == "" || tagged[slug] {
+				continue
+			}
+			tagged[slug] = true
+
+			if tags[slug] == nil {
+				tags[slug] = &siteLink{Title: category, Href: "tags/" + slug + ".html"}
+			}
+			tags[slug].Count++
+			byTag[slug] = append(byTag[slug], link)
+
+			article.Tags = append(article.Tags, *tags[slug])
+		}
+
+		if entry.Type == "glossary" {
+			letter := glossaryLetter(title)
+			letters[letter] = append(letters[letter], link)
+		}
+
+		if err := builder.write(link.Href, "article", title, article); err != nil {
+			return err
+		}
+
+		index = append(index, searchItem{
+			Title: title,
+			Href:  link.Href,
+			Site:  entry.Site,
+			Date:  entry.Date().Format("2006-01-02"),
+			Text:  strings.TrimSpace(entry.Summary() + " " + utils.Excerpt(entry.Content(), maxSearchText)),
+		})
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Title < sources[j].Title
+	})
+
+	for i, source := range sources {
+		sources[i].Count = len(bySite[source.Title])
+
+		if err := builder.write(source.Href, "list", source.Title, bySite[source.Title]); err != nil {
+			return err
+		}
+	}
+
+	if err := builder.write("index.html", "index", builder.name, sources); err != nil {
+		return err
+	}
+
+	if err := builder.writeGlossary(letters); err != nil {
+		return err
+	}
+
+	if err := builder.writeTags(tags, byTag); err != nil {
+		return err
+	}
+
+	if err := builder.write("search.html", "search", "Search", nil); err != nil {
+		return err
+	}
+
+	return builder.writeAssets(index)
+}
+
+// writeGlossary writes the A–Z index of glossary terms and a page of the
+// terms of every letter.
+func (builder *siteBuilder) writeGlossary(letters map[string][]siteLink) error {
+	glossary := siteGlossary{}
+
+	for _, letter := range append(strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZ", ""), otherLetter) {
+		link := siteLink{Title: letter, Count: len(letters[letter])}
+		if link.Count > 0 {
+			link.Href = "glossary/" + glossaryPage(letter)
+		}
+
+		glossary.Letters = append(glossary.Letters, link)
+	}
+
+	if err := builder.write("glossary/index.html", "glossary", "Glossary", glossary); err != nil {
+		return err
+	}
+
+	for letter, terms := range letters {
+		sort.Slice(terms, func(i, j int) bool {
+			return strings.ToLower(terms[i].Title) < strings.ToLower(terms[j].Title)
+		})
+
+		page := glossary
+		page.Letter, page.Terms = letter, terms
+
+		if err := builder.write("glossary/"+glossaryPage(letter), "glossary", "Glossary: "+letter, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTags writes the index of tags, most used first, and a page of the
+// documents of every tag.
+func (builder *siteBuilder) writeTags(tags map[string]*siteLink, byTag map[string][]siteLink) error {
+	links := make([]siteLink, 0, len(tags))
+	for _, tag := range tags {
+		links = append(links, *tag)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Count != links[j].Count {
+			return links[i].Count > links[j].Count
+		}
+		return strings.ToLower(links[i].Title) < strings.ToLower(links[j].Title)
+	})
+
+	if err := builder.write("tags/index.html", "tags", "Tags", links); err != nil {
+		return err
+	}
+
+	for slug, tag := range tags {
+		if err := builder.write(tag.Href, "list", tag.Title, byTag[slug]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAssets copies the style sheet and script of the site and writes the
+// search index as a script, so search works from pages opened as files.
+func (builder *siteBuilder) writeAssets(index []searchItem) error {
+	for _, asset := range []string{"style.css", "search.js"} {
+		body, err := fs.ReadFile(siteFiles, "site/"+asset)
+		if err != nil {
+			return err
+		}
+
+		if err := builder.writeFile(asset, body); err != nil {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	return builder.writeFile("search-index.js", append(append([]byte("window.SEARCH_INDEX = "), body...), ";\n"...))
+}
+
+// write renders the page template with body to the file at href.
+func (builder *siteBuilder) write(href string, page string, title string, body interface{}) error {
+	var rendered strings.Builder
+
+	data := sitePage{
+		Root:  strings.Repeat("../", strings.Count(href, "/")),
+		Name:  builder.name,
+		Title: title,
+		Body:  body,
+	}
+
+	if err := siteTemplates[page].ExecuteTemplate(&rendered, "layout.html", data); err != nil {
+		return fmt.Errorf("unable to render %s: %v", href, err)
+	}
+
+	builder.pages++
+
+	return builder.writeFile(href, []byte(rendered.String()))
+}
+
+func (builder *siteBuilder) writeFile(href string, body []byte) error {
+	file := filepath.Join(builder.dir, filepath.FromSlash(href))
+
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, body, 0644)
+}
+
+// glossaryLetter returns the letter a term is listed under.
+func glossaryLetter(term string) string {
+	slug := utils.Slug(term)
+	if slug == "" || slug[0] < 'a' || slug[0] > 'z' {
+		return otherLetter
+	}
+
+	return strings.ToUpper(slug[:1])
+}
+
+// glossaryPage returns the file of the terms of letter.
+func glossaryPage(letter string) string {
+	if letter == otherLetter {
+		return "0-9.html"
+	}
+
+	return strings.ToLower(letter) + ".html"
+}
@@ -0,0 +1,61 @@
+// Searches window.SEARCH_INDEX, written by scrapers site build, for the
+// documents containing every word of the query, titles first.
+(function () {
+  var input = document.getElementById("query");
+  var status = document.getElementById("status");
+  var results = document.getElementById("results");
+  var index = (window.SEARCH_INDEX || []).map(function (item) {
+    return { item: item, title: item.t.toLowerCase(), text: (item.s + " " + item.x).toLowerCase() };
+  });
+
+  function search() {
+    var words = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+
+    if (words.length === 0) {
+      status.textContent = index.length + " documents";
+      return;
+    }
+
+    var matches = [];
+    index.forEach(function (doc) {
+      var score = 0;
+      for (var i = 0; i < words.length; i++) {
+        if (doc.title.indexOf(words[i]) >= 0) {
+          score += 10;
+        } else if (doc.text.indexOf(words[i]) >= 0) {
+          score += 1;
+        } else {
+          return;
+        }
+      }
+      matches.push({ score: score, item: doc.item });
+    });
+
+    matches.sort(function (a, b) {
+      return b.score - a.score || (a.item.d < b.item.d ? 1 : -1);
+    });
+
+    status.textContent = matches.length + " found";
+    matches.slice(0, 100).forEach(function (match) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = match.item.u;
+      a.textContent = match.item.t;
+      var meta = document.createElement("time");
+      meta.textContent = match.item.s + " · " + match.item.d;
+      var p = document.createElement("p");
+      p.textContent = match.item.x.slice(0, 200);
+      li.append(a, " ", meta, p);
+      results.appendChild(li);
+    });
+  }
+
+  var query = new URLSearchParams(location.search).get("q");
+  if (query) {
+    input.value = query;
+  }
+
+  input.addEventListener("input", search);
+  search();
+})();
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"scripts/utils"
+	"strings"
+	"testing"
+)
+
+func TestSiteLinksEveryTagOnce(t *testing.T) {
+	entry := utils.Entry{
+		Site:       "example.com",
+		Type:       "article",
+		Id:         "https://example.com/staking",
+		Fields:     map[string]interface{}{"title": "Staking", "link": "https://example.com/staking", "category": "DeFi", "tags": "defi, Staking, DeFi"},
+		Separators: map[string]string{"tags": ","},
+	}
+
+	builder := siteBuilder{dir: t.TempDir(), name: "Test"}
+	if err := builder.build([]utils.Entry{entry}); err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := os.ReadFile(filepath.Join(builder.dir, "example.com", utils.Filename("Staking", entry.Id)+".html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(article), `tags/defi.html"`); count != 1 {
+		t.Errorf("got %d links to the defi tag, want one", count)
+	}
+
+	tag, err := os.ReadFile(filepath.Join(builder.dir, "tags", "defi.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(tag), utils.Filename("Staking", entry.Id)+".html"); count != 1 {
+		t.Errorf("got the document %d times on the tag page, want once", count)
+	}
+
+	pages, err := os.ReadDir(filepath.Join(builder.dir, "tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Errorf("got %d tag pages, want the index, defi and staking", len(pages))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"scripts/utils"
+	"strings"
+)
+
+// glossarySites are the sites scraping glossaries, whose terms get notes
+// of their own in vaults by default.
+var glossarySites = []string{"academy.binance.com", "coinmarketcap.com", "smithandcrown", "coindeskglossary"}
+
+func init() {
+	registerCommand("vault", "write the scraped documents as an Obsidian or Logseq vault of linked notes", runVault)
+}
+
+func runVault(args []string) error {
+	flags := flag.NewFlagSet("vault", flag.ExitOnError)
+
+	output := flags.String("output", utils.DefaultOutput(), "read documents from `DIR`")
+	sites := flags.String("site", "", "write notes of the comma separated `SITES` only")
+	glossaries := flags.String("glossary", strings.Join(glossarySites, ","), "make notes of the glossary terms of the comma separated `SITES` and link their mentions")
+	dir := flags.String("dir", "", "write the vault to `DIR` (default <output>/vault)")
+
+	flags.Parse(args)
+
+	if *dir == "" {
+		*dir = filepath.Join(*output, "vault")
+	}
+
+	entries, err := utils.LoadCorpus(*output, nil)
+	if err != nil {
+		return err
+	}
+
+	vault := utils.NewVault(*dir, entries, list(*glossaries))
+	if err := vault.WriteGlossary(); err != nil {
+		return err
+	}
+
+	notes := 0
+	for _, entry := range entries {
+		if len(list(*sites)) > 0 && !contains(list(*sites), entry.Site) {
+			continue
+		}
+
+		written, err := vault.Write(entry)
+		if err != nil {
+			return fmt.Errorf("unable to write the note of %s: %v", entry.Path, err)
+		}
+
+		if written {
+			notes++
+		}
+	}
+
+	fmt.Printf("Wrote %d notes and %d glossary terms to %s\n", notes, vault.Terms(), *dir)
+
+	return nil
+}
@@ -1,13 +1,9 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
-	"strings"
 	"time"
 
 	"github.com/gocolly/colly"
@@ -36,25 +32,6 @@ func (glossary Glossary) GetValues() []string {
 	return values
 }
 
-func writeToCsv(csvFile os.File, glossary Glossary) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(glossary.GetHeaders())
-	csvWriter.Write(glossary.GetValues())
-	csvWriter.Flush()
-}
-
-func writeToMd(mdFile os.File, glossary Glossary) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	---
-	%s`,
-		glossary.Title, glossary.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getGlossaries(c *colly.Collector) []Glossary {
 	glossaries := make([]Glossary, 0)
 
@@ -76,10 +53,7 @@ func getGlossaries(c *colly.Collector) []Glossary {
 		})
 
 		c.OnHTML(".description-col", func(h *colly.HTMLElement) {
-			glossary.Content = h.Text
-			glossary.Content = strings.Replace(glossary.Content, "\n", "", -1)
-			glossary.Content = strings.Replace(glossary.Content, "\t", "", -1)
-			glossary.Content = strings.Replace(glossary.Content, "\"", "'", -1)
+			glossary.Content = utils.ElementMarkdown(h)
 		})
 
 		c.Visit(fmt.Sprintf("https://www.smithandcrown.com/%s", h.ChildAttr("a", "href")))
@@ -95,70 +69,26 @@ func getGlossaries(c *colly.Collector) []Glossary {
 }
 
 func main() {
-	if err := os.Mkdir("../../output/smithandcrown", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "smithandcrown",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "content": 0.95},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   ".glossary-title__wrap",
+		"content": ".description-col",
+	})
+
 	glosaries := getGlossaries(c)
 
 	for _, glossary := range glosaries {
-		file, err := json.MarshalIndent(glossary, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if err := os.Mkdir(fmt.Sprintf("../../output/smithandcrown/%s", glossary.Title), os.ModePerm); err != nil {
-			if !strings.Contains(err.Error(), "file exists") {
-				log.Fatal(err)
-			}
-		}
-
-		filepathJson := fmt.Sprintf("../../output/smithandcrown/%s/article.json", glossary.Title)
-		filepathCsv := fmt.Sprintf("../../output/smithandcrown/%s/article.csv", glossary.Title)
-		filepathMd := fmt.Sprintf("../../output/smithandcrown/%s/article.md", glossary.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+		utils.Observe(glossary)
 
-		if err != nil && f == nil {
-
-			f.Close()
-
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, glossary)
-
-			writeToMd(*createdMd, glossary)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdMd.Close()
-			createdCsv.Close()
-
-		} else {
-			f.Close()
-		}
+		utils.Save(glossary.Title, glossary)
 	}
+
+	utils.Finish()
 }
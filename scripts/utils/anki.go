@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deck is a deck of Anki flashcards, written as an .apkg package.
+type Deck struct {
+	// Name is the name of the deck, e.g. "Crypto glossary::coindeskglossary"
+	// for a subdeck.
+	Name string
+	// Cards are the cards of the deck, in the order they are studied.
+	Cards []Card
+}
+
+// Card is a flashcard showing a term and, on the back, its definition.
+type Card struct {
+	// Key identifies the card across exports, e.g. its site and term. Cards
+	// with the same key update the notes of earlier imports.
+	Key string
+	// Term is the front of the card, as text.
+	Term string
+	// Definition is the back of the card, as HTML.
+	Definition string
+	// Source names where the definition is from, e.g. a link to it.
+	Source string
+	// Tags are the tags of the note, without spaces.
+	Tags []string
+}
+
+// ankiModel names the note type of the cards, which Anki matches by id on
+// import.
+const ankiModel = "Scrapers glossary term"
+
+const ankiCss = `.card {
+  font-family: arial;
+  font-size: 20px;
+  text-align: center;
+  color: black;
+  background-color: white;
+}
+
+.definition {
+  text-align: left;
+}
+
+.source {
+  font-size: 14px;
+  color: #777;
+}
+`
+
+const ankiSchema = `
+CREATE TABLE col (
+	id integer PRIMARY KEY, crt integer NOT NULL, mod integer NOT NULL, scm integer NOT NULL, ver integer NOT NULL,
+	dty integer NOT NULL, usn integer NOT NULL, ls integer NOT NULL, conf text NOT NULL, models text NOT NULL,
+	decks text NOT NULL, dconf text NOT NULL, tags text NOT NULL
+);
+CREATE TABLE notes (
+	id integer PRIMARY KEY, guid text NOT NULL, mid integer NOT NULL, mod integer NOT NULL, usn integer NOT NULL,
+	tags text NOT NULL, flds text NOT NULL, sfld integer NOT NULL, csum integer NOT NULL, flags integer NOT NULL,
+	data text NOT NULL
+);
+CREATE TABLE cards (
+	id integer PRIMARY KEY, nid integer NOT NULL, did integer NOT NULL, ord integer NOT NULL, mod integer NOT NULL,
+	usn integer NOT NULL, type integer NOT NULL, queue integer NOT NULL, due integer NOT NULL, ivl integer NOT NULL,
+	factor integer NOT NULL, reps integer NOT NULL, lapses integer NOT NULL, left integer NOT NULL,
+	odue integer NOT NULL, odid integer NOT NULL, flags integer NOT NULL, data text NOT NULL
+);
+CREATE TABLE revlog (
+	id integer PRIMARY KEY, cid integer NOT NULL, usn integer NOT NULL, ease integer NOT NULL, ivl integer NOT NULL,
+	lastIvl integer NOT NULL, factor integer NOT NULL, time integer NOT NULL, type integer NOT NULL
+);
+CREATE TABLE graves (usn integer NOT NULL, oid integer NOT NULL, type integer NOT NULL);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);
+`
+
+// WriteApkg writes the decks as the Anki package at path.
+func WriteApkg(path string, decks ...Deck) error {
+	temp, err := os.MkdirTemp(filepath.Dir(path), ".apkg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+
+	collection := filepath.Join(temp, "collection.anki2")
+	if err := writeCollection(collection, decks); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	archive := zip.NewWriter(&body)
+
+	if err := addFile(archive, "collection.anki2", collection); err != nil {
+		return err
+	}
+
+	// the cards have no images or sounds
+	media, err := archive.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(media, "{}"); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return replace(path, body.Bytes())
+}
+
+func addFile(archive *zip.Writer, name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, file)
+
+	return err
+}
+
+// writeCollection writes the decks to a new Anki 2.1 collection at path.
+func writeCollection(path string, decks []Deck) error {
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	modelId := ankiId(ankiModel)
+
+	deckJson := map[string]interface{}{"1": ankiDeck(1, "Default", now)}
+	for _, deck := range decks {
+		id := ankiId("deck\n" + deck.Name)
+		deckJson[strconv.FormatInt(id, 10)] = ankiDeck(id, deck.Name, now)
+	}
+
+	model := map[string]interface{}{
+		strconv.FormatInt(modelId, 10): map[string]interface{}{
+			"id":    modelId,
+			"name":  ankiModel,
+			"type":  0,
+			"mod":   now.Unix(),
+			"usn":   -1,
+			"sortf": 0,
+			"did":   1,
+			"tmpls": []interface{}{map[string]interface{}{
+				"name":  "Term",
+				"ord":   0,
+				"qfmt":  "{{Term}}",
+				"afmt":  "{{FrontSide}}\n\n<hr id=answer>\n\n<div class=definition>{{Definition}}</div>\n{{#Source}}<p class=source>{{Source}}</p>{{/Source}}",
+				"bqfmt": "",
+				"bafmt": "",
+				"did":   nil,
+			}},
+			"flds":      []interface{}{ankiField("Term", 0), ankiField("Definition", 1), ankiField("Source", 2)},
+			"css":       ankiCss,
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+			"tags":      []string{},
+			"vers":      []string{},
+		},
+	}
+
+	conf := map[string]interface{}{
+		"activeDecks": []int{1}, "curDeck": 1, "newSpread": 0, "collapseTime": 1200, "timeLim": 0, "estTimes": true,
+		"dueCounts": true, "curModel": modelId, "nextPos": 1, "sortType": "noteFld", "sortBackwards": false, "addToCur": true,
+	}
+
+	dconf := map[string]interface{}{"1": map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]interface{}{"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": true, "separate": true},
+		"rev": map[string]interface{}{"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500, "bury": true, "minSpace": 1, "hardFactor": 1.2},
+		"lapse": map[string]interface{}{"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0},
+	}}
+
+	values := make([]interface{}, 0)
+	for _, value := range []interface{}{conf, model, deckJson, dconf} {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		values = append(values, string(encoded))
+	}
+
+	_, err = db.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		append([]interface{}{now.Truncate(24 * time.Hour).Unix(), now.UnixMilli(), now.UnixMilli()}, values...)...)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	due := 0
+	for _, deck := range decks {
+		deckId := ankiId("deck\n" + deck.Name)
+
+		for _, card := range deck.Cards {
+			due++
+
+			noteId := ankiId("note\n" + card.Key)
+			// the fields are HTML, and the sort field is their text without
+			// markup, which for the term is the term itself
+			fields := []string{html.EscapeString(card.Term), card.Definition, card.Source}
+
+			tags := ""
+			if len(card.Tags) > 0 {
+				tags = " " + strings.Join(card.Tags, " ") + " "
+			}
+
+			_, err := tx.Exec(`INSERT OR REPLACE INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+				noteId, ankiGuid(card.Key), modelId, now.Unix(), tags, strings.Join(fields, "\x1f"), card.Term, ankiChecksum(card.Term))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT OR REPLACE INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+				ankiId("card\n"+card.Key), noteId, deckId, now.Unix(), due)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func ankiDeck(id int64, name string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "mod": now.Unix(), "usn": -1, "conf": 1, "desc": "", "dyn": 0, "collapsed": false,
+		"extendNew": 10, "extendRev": 50, "newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0},
+		"timeToday": []int{0, 0},
+	}
+}
+
+func ankiField(name string, ord int) map[string]interface{} {
+	return map[string]interface{}{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+}
+
+// ankiId returns a positive id derived from key, so the same note, card,
+// deck or model gets the same id in every export.
+func ankiId(key string) int64 {
+	sum := sha1.Sum([]byte(key))
+
+	// 52 bits stay exact in the JSON numbers of the collection
+	return int64(binary.BigEndian.Uint64(sum[:8]) >> 12)
+}
+
+// ankiGuid returns the guid Anki matches notes by on import.
+func ankiGuid(key string) string {
+	sum := sha1.Sum([]byte("guid\n" + key))
+
+	return hex.EncodeToString(sum[:10])
+}
+
+// ankiChecksum returns the checksum of the sort field Anki finds duplicates
+// with: the first 8 hex digits of the sha1 of its text, stripped of markup.
+func ankiChecksum(text string) int64 {
+	sum := sha1.Sum([]byte(text))
+
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApkgEscapesTerms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "glossary.apkg")
+
+	deck := Deck{Name: "Glossary", Cards: []Card{
+		{Key: "example.com\nx<y>", Term: "x < y & <b>z</b>", Definition: "<p>less</p>", Source: "example.com"},
+	}}
+	if err := WriteApkg(path, deck); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	collection := filepath.Join(dir, "collection.anki2")
+	for _, file := range archive.File {
+		if file.Name != "collection.anki2" {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(collection, body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", "file:"+collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var fields, sortField string
+	var sum int64
+	if err := db.QueryRow("SELECT flds, sfld, csum FROM notes").Scan(&fields, &sortField, &sum); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "x &lt; y &amp; &lt;b&gt;z&lt;/b&gt;\x1f<p>less</p>\x1fexample.com"; fields != want {
+		t.Errorf("got fields %q, want %q", fields, want)
+	}
+	if sortField != "x < y & <b>z</b>" {
+		t.Errorf("got sort field %q, want the text of the term", sortField)
+	}
+
+	text := sha1.Sum([]byte(sortField))
+	if want := int64(binary.BigEndian.Uint32(text[:4])); sum != want {
+		t.Errorf("got checksum %d, want %d of the sort field", sum, want)
+	}
+}
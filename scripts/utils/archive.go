@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ManifestName is the first file of every archive, describing it.
+const ManifestName = "manifest.json"
+
+// manifestVersion is the version of the manifests archives are written
+// with.
+const manifestVersion = 1
+
+// Manifest describes a snapshot archive of the output tree.
+type Manifest struct {
+	// Version is the version of the manifest format.
+	Version int `json:"version"`
+	// Name is the name of the archive, e.g. all-20240415T100000Z.
+	Name string `json:"name"`
+	// Created is when the snapshot was taken.
+	Created time.Time `json:"created"`
+	// Sites are the sites in the snapshot, or empty for all sites.
+	Sites []string `json:"sites"`
+	// Base names the snapshot an incremental archive has the changes since.
+	// Full archives have none.
+	Base string `json:"base,omitempty"`
+	// Files are the hashes of every file of the snapshot by path, relative
+	// to the output root, including the ones unchanged since Base.
+	Files map[string]string `json:"files"`
+	// Included are the paths of the files the archive holds.
+	Included []string `json:"included"`
+	// Deleted are the paths of the files of Base deleted since.
+	Deleted []string `json:"deleted,omitempty"`
+	// Documents are the documents the archive holds files of.
+	Documents []ManifestDocument `json:"documents"`
+}
+
+// ManifestDocument is a document in a snapshot archive.
+type ManifestDocument struct {
+	Site   string    `json:"site"`
+	Type   string    `json:"type"`
+	Id     string    `json:"id"`
+	Path   string    `json:"path"`
+	Saved  time.Time `json:"saved"`
+	Sha256 string    `json:"sha256"`
+}
+
+// WriteArchive writes a tar+zstd snapshot of the output tree under root of
+// the sites, or of all sites when none are given, to w. With a base it only
+// holds the files added or changed since the snapshot of base.
+func WriteArchive(w io.Writer, root string, name string, sites []string, base *Manifest) (Manifest, error) {
+	manifest := Manifest{
+		Version:   manifestVersion,
+		Name:      name,
+		Created:   time.Now().UTC(),
+		Sites:     sites,
+		Files:     map[string]string{},
+		Included:  make([]string, 0),
+		Documents: make([]ManifestDocument, 0),
+	}
+
+	entries, err := LoadCorpus(root, sites)
+	if err != nil {
+		return manifest, err
+	}
+
+	// documents are saved under the directory of their site, which holds
+	// the other files of the site too
+	dirs := make([]string, 0)
+	for _, entry := range entries {
+		if dir := strings.Split(entry.Path, "/")[0]; !contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	// files are copied to a staging directory as they are hashed, so that
+	// the archive holds the files as hashed even when a run changes them
+	// while the snapshot is taken. Its name starts with a dot, so it is not
+	// read as a site.
+	stage, err := os.MkdirTemp(root, ".archive-")
+	if err != nil {
+		return manifest, err
+	}
+	defer os.RemoveAll(stage)
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(file string, info fs.DirEntry, err error) error {
+			if err != nil || !info.Type().IsRegular() {
+				return err
+			}
+
+			relative, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+
+			staged := filepath.Join(stage, relative)
+			sum, err := stageFile(file, staged)
+			if err != nil {
+				return err
+			}
+
+			relative = filepath.ToSlash(relative)
+			manifest.Files[relative] = sum
+
+			if base == nil || base.Files[relative] != sum {
+				manifest.Included = append(manifest.Included, relative)
+				return nil
+			}
+
+			return os.Remove(staged)
+		})
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	sort.Strings(manifest.Included)
+
+	if base != nil {
+		manifest.Base = base.Name
+
+		for file := range base.Files {
+			if _, ok := manifest.Files[file]; !ok && inSites(file, dirs, sites) {
+				manifest.Deleted = append(manifest.Deleted, file)
+			}
+		}
+		sort.Strings(manifest.Deleted)
+	}
+
+	for _, entry := range entries {
+		if base == nil || base.Files[entry.Path] != manifest.Files[entry.Path] {
+			manifest.Documents = append(manifest.Documents, ManifestDocument{
+				Site:   entry.Site,
+				Type:   entry.Type,
+				Id:     entry.Id,
+				Path:   entry.Path,
+				Saved:  entry.Saved.UTC(),
+				Sha256: manifest.Files[entry.Path],
+			})
+		}
+	}
+
+	compressed, err := zstd.NewWriter(w)
+	if err != nil {
+		return manifest, err
+	}
+
+	archive := tar.NewWriter(compressed)
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	header := &tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(body)), ModTime: manifest.Created, Typeflag: tar.TypeReg}
+	if err := archive.WriteHeader(header); err != nil {
+		return manifest, err
+	}
+	if _, err := archive.Write(body); err != nil {
+		return manifest, err
+	}
+
+	for _, file := range manifest.Included {
+		if err := addToArchive(archive, stage, file); err != nil {
+			return manifest, fmt.Errorf("unable to archive %s: %v", file, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return manifest, err
+	}
+
+	return manifest, compressed.Close()
+}
+
+// inSites reports whether the file of the base snapshot is part of the
+// snapshot being taken, so its absence means it was deleted.
+func inSites(file string, dirs []string, sites []string) bool {
+	if len(sites) == 0 {
+		return true
+	}
+
+	return contains(dirs, strings.Split(file, "/")[0])
+}
+
+func addToArchive(archive *tar.Writer, root string, file string) error {
+	source, err := os.Open(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: file, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(archive, source)
+
+	return err
+}
+
+// ReadManifest returns the manifest of the archive at path.
+func ReadManifest(path string) (Manifest, error) {
+	manifest := Manifest{}
+
+	err := readArchive(path, func(header *tar.Header, body io.Reader) error {
+		if header.Name != ManifestName {
+			return errStopArchive
+		}
+
+		return json.NewDecoder(body).Decode(&manifest)
+	})
+	if err == errStopArchive {
+		err = nil
+	}
+
+	if err == nil && manifest.Version == 0 {
+		err = fmt.Errorf("%s has no manifest", path)
+	}
+
+	return manifest, err
+}
+
+// RestoreArchive extracts the archive at path to the directory to, checking
+// the hashes of the files against its manifest and deleting the files an
+// incremental archive lists as deleted.
+func RestoreArchive(path string, to string) (Manifest, error) {
+	manifest := Manifest{}
+
+	err := readArchive(path, func(header *tar.Header, body io.Reader) error {
+		if header.Name == ManifestName {
+			return json.NewDecoder(body).Decode(&manifest)
+		}
+
+		if manifest.Version == 0 {
+			return fmt.Errorf("%s does not start with a manifest", path)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		file, err := archivedPath(to, header.Name)
+		if err != nil {
+			return err
+		}
+
+		want, ok := manifest.Files[header.Name]
+		if !ok {
+			return fmt.Errorf("%s is not in the manifest", header.Name)
+		}
+
+		hash := sha256.New()
+		content, err := io.ReadAll(io.TeeReader(body, hash))
+		if err != nil {
+			return err
+		}
+
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != want {
+			return fmt.Errorf("%s is corrupt, its sha256 is %s instead of %s", header.Name, sum, want)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			return err
+		}
+
+		return replace(file, content)
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	for _, deleted := range manifest.Deleted {
+		file, err := archivedPath(to, deleted)
+		if err != nil {
+			return manifest, err
+		}
+
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return manifest, err
+		}
+	}
+
+	return manifest, nil
+}
+
+// archivedPath returns where the file name of an archive is extracted to
+// under to, refusing names that would escape it.
+func archivedPath(to string, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("refusing to extract %s outside of %s", name, to)
+	}
+
+	return filepath.Join(to, filepath.FromSlash(clean)), nil
+}
+
+// errStopArchive stops reading an archive early.
+var errStopArchive = errors.New("stop reading archive")
+
+func readArchive(path string, read func(header *tar.Header, body io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decompressed, err := zstd.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+
+	archive := tar.NewReader(decompressed)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", path, err)
+		}
+
+		if err := read(header, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// stageFile copies the file at path to staged, keeping its modification
+// time, and returns the sha256 of the copy.
+func stageFile(path string, staged string) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(staged), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	target, err := os.Create(staged)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(target, hash), source)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Chtimes(staged, info.ModTime(), info.ModTime()); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchiveDoc writes a document of example.com under root as the files
+// sink does.
+func writeArchiveDoc(t *testing.T, root string, name string, body string) {
+	t.Helper()
+
+	dir := filepath.Join(root, "example.com")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	done := `{"id": "https://example.com/` + name + `", "site": "example.com", "type": "article", "saved": "2024-01-15T00:00:00Z", "files": {"` + name + `.json": ""}}`
+	for file, content := range map[string]string{name + ".json": body, name + ".done": done} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeTestArchive(t *testing.T, root string, name string, base *Manifest) (string, Manifest) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name+".tar.zst")
+
+	var archive bytes.Buffer
+	manifest, err := WriteArchive(&archive, root, name, nil, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, manifest
+}
+
+func TestArchiveRestoresIncrementalSnapshots(t *testing.T) {
+	root := t.TempDir()
+	writeArchiveDoc(t, root, "one", `{"title": "one"}`)
+	writeArchiveDoc(t, root, "two", `{"title": "two"}`)
+
+	full, manifest := writeTestArchive(t, root, "all-full", nil)
+	if len(manifest.Documents) != 2 || len(manifest.Included) != 4 {
+		t.Errorf("got %d documents and %d files, want every file of both documents", len(manifest.Documents), len(manifest.Included))
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d entries in the output, want the staged files removed", len(entries))
+	}
+
+	writeArchiveDoc(t, root, "one", `{"title": "one again"}`)
+	os.Remove(filepath.Join(root, "example.com", "two.json"))
+	os.Remove(filepath.Join(root, "example.com", "two.done"))
+
+	incremental, changes := writeTestArchive(t, root, "all-incremental", &manifest)
+	if len(changes.Included) != 1 || len(changes.Deleted) != 2 || changes.Base != "all-full" {
+		t.Errorf("got files %v deleting %v, want the changed document only", changes.Included, changes.Deleted)
+	}
+
+	to := t.TempDir()
+	for _, path := range []string{full, incremental} {
+		if _, err := RestoreArchive(path, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restored, err := os.ReadFile(filepath.Join(to, "example.com", "one.json"))
+	if err != nil || string(restored) != `{"title": "one again"}` {
+		t.Errorf("got %q (%v), want the changed document", restored, err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "example.com", "two.json")); !os.IsNotExist(err) {
+		t.Errorf("deleted document was restored: %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// BusEnv names the environment variable with the broker of the bus sink.
+const BusEnv = "SCRAPERS_BUS_URL"
+
+// BusOptions configures the bus sink.
+type BusOptions struct {
+	// Url is the broker, nats://host:port for NATS JetStream or
+	// kafka://host:port[,host:port] for Kafka.
+	Url string
+	// Prefix starts the subjects or topics, followed by the site and the
+	// document type, with dots in them replaced by underscores, e.g.
+	// scrapers.cointelegraph_com.article.
+	Prefix string
+	// Format encodes events as "json" or "protobuf", see event.proto.
+	Format string
+	// Stream is the JetStream stream storing the subjects, created when
+	// missing.
+	Stream string
+}
+
+var busOptions BusOptions
+
+// busEvent is an event waiting in the outbox until the broker acknowledges
+// it.
+type busEvent struct {
+	Subject string `json:"subject"`
+	Key     string `json:"key"`
+	Id      string `json:"id"`
+	Payload []byte `json:"payload"`
+}
+
+// publisher delivers events to a broker, returning once the broker has
+// stored them.
+type publisher interface {
+	publish(event busEvent) error
+	close() error
+}
+
+// busSink publishes an event for every document that is new or changed
+// since it was last published. Events are written to an outbox in
+// output/<site>/.outbox first and removed once the broker acknowledged them,
+// so events of runs that could not reach the broker are published by the
+// next run, at least once.
+type busSink struct {
+	outbox    string
+	published *changes
+	publisher publisher
+}
+
+func init() {
+	registerSink("bus", func(flags *flag.FlagSet) {
+		flags.StringVar(&busOptions.Url, "bus-url", "", "publish events to the nats:// or kafka:// broker at `URL` (default $"+BusEnv+")")
+		flags.StringVar(&busOptions.Prefix, "bus-prefix", "scrapers", "start subjects and topics with `PREFIX`")
+		flags.StringVar(&busOptions.Format, "bus-format", "json", "encode events as `json` or protobuf")
+		flags.StringVar(&busOptions.Stream, "bus-stream", "SCRAPERS", "store subjects in the JetStream `STREAM`")
+	}, openBus)
+}
+
+func openBus() (Sink, error) {
+	if busOptions.Format != "json" && busOptions.Format != "protobuf" {
+		return nil, fmt.Errorf("unknown format %q", busOptions.Format)
+	}
+
+	// the environment is read after parsing so that -h does not print the
+	// credentials of the URL
+	if busOptions.Url == "" {
+		busOptions.Url = os.Getenv(BusEnv)
+	}
+
+	broker, err := url.Parse(busOptions.Url)
+	if busOptions.Url == "" || err != nil {
+		return nil, fmt.Errorf("no broker, set --bus-url or %s", BusEnv)
+	}
+
+	if broker.Scheme != "nats" && broker.Scheme != "kafka" {
+		return nil, fmt.Errorf("unknown broker %q, expected nats:// or kafka://", busOptions.Url)
+	}
+
+	sink := &busSink{outbox: filepath.Join(options.Output, site.Name, ".outbox")}
+
+	if err := os.MkdirAll(sink.outbox, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	sink.published, err = loadChanges(filepath.Join(options.Output, site.Name, ".published"))
+	if err != nil {
+		return nil, err
+	}
+
+	if broker.Scheme == "nats" {
+		sink.publisher, err = openNats(busOptions.Url)
+	} else {
+		sink.publisher, err = openKafka(broker.Host)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "broker unavailable, keeping events in %s: %v\n", sink.outbox, err)
+		sink.publisher = nil
+	}
+
+	sink.drain()
+
+	return sink, nil
+}
+
+func (sink *busSink) Write(record Record) error {
+	kind := sink.published.kind(record)
+	if kind == "" {
+		return nil
+	}
+
+	payload, err := encodeEvent(kind, record)
+	if err != nil {
+		return err
+	}
+
+	id := newDocumentRow(record).id
+	eventSum := sha1.Sum([]byte(id + "\n" + checksum(record.Json)))
+	event := busEvent{
+		Subject: busSubject(record),
+		Key:     id,
+		Id:      hex.EncodeToString(eventSum[:]),
+		Payload: payload,
+	}
+
+	entry, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(sink.outbox, fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), event.Id))
+	if err := replace(path, entry); err != nil {
+		return err
+	}
+
+	sink.published.mark(record)
+	sink.deliver(path, event)
+
+	return nil
+}
+
+// deliver publishes the event of the outbox file at path and removes the
+// file once the broker acknowledged it. When the broker fails, the run
+// stops publishing and leaves the rest of its events in the outbox.
+func (sink *busSink) deliver(path string, event busEvent) {
+	if sink.publisher == nil {
+		return
+	}
+
+	if err := sink.publisher.publish(event); err != nil {
+		fmt.Fprintf(os.Stderr, "broker unavailable, keeping events in %s: %v\n", sink.outbox, err)
+		sink.publisher.close()
+		sink.publisher = nil
+		return
+	}
+
+	os.Remove(path)
+}
+
+// drain publishes the events left in the outbox, oldest first.
+func (sink *busSink) drain() {
+	entries, err := os.ReadDir(sink.outbox)
+	if err != nil {
+		return
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(sink.outbox, name)
+
+		file, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		event := busEvent{}
+		if err := json.Unmarshal(file, &event); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read event %s: %v\n", path, err)
+			continue
+		}
+
+		sink.deliver(path, event)
+	}
+}
+
+// Close publishes the events still in the outbox and saves which documents
+// were published.
+func (sink *busSink) Close() error {
+	sink.drain()
+
+	if sink.publisher != nil {
+		sink.publisher.close()
+	}
+
+	return sink.published.save()
+}
+
+// busSubject returns the subject or topic of the events of record.
+func busSubject(record Record) string {
+	token := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(".*> \t", r) {
+				return '_'
+			}
+			return r
+		}, text)
+	}
+
+	return busOptions.Prefix + "." + token(site.Name) + "." + token(documentType(record.Doc))
+}
+
+// documentEvent returns the event of kind about record as JSON encodes it,
+// with the fields of event.proto.
+func documentEvent(kind string, record Record) map[string]interface{} {
+	return map[string]interface{}{
+		"event":    kind,
+		"id":       newDocumentRow(record).id,
+		"site":     site.Name,
+		"type":     documentType(record.Doc),
+		"filename": record.Name,
+		"saved_at": record.Saved.UnixMilli(),
+		"fields":   documentFields(record),
+	}
+}
+
+// documentFields returns the fields of record by their renamed headers.
+func documentFields(record Record) map[string]string {
+	headers, values := record.Fields()
+
+	fields := map[string]string{}
+	for i, header := range headers {
+		if i < len(values) {
+			fields[header] = values[i]
+		}
+	}
+
+	return fields
+}
+
+// encodeEvent returns the event of kind about record in the format of the
+// run. The JSON and protobuf events have the same fields, see event.proto.
+func encodeEvent(kind string, record Record) ([]byte, error) {
+	if busOptions.Format == "json" {
+		return json.Marshal(documentEvent(kind, record))
+	}
+
+	fields := documentFields(record)
+
+	var message []byte
+	for number, value := range []string{kind, newDocumentRow(record).id, site.Name, documentType(record.Doc), record.Name} {
+		message = protowire.AppendTag(message, protowire.Number(number+1), protowire.BytesType)
+		message = protowire.AppendString(message, value)
+	}
+
+	message = protowire.AppendTag(message, 6, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(record.Saved.UnixMilli()))
+
+	keys := make([]string, 0)
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, fields[key])
+
+		message = protowire.AppendTag(message, 7, protowire.BytesType)
+		message = protowire.AppendBytes(message, entry)
+	}
+
+	return message, nil
+}
+
+// natsPublisher publishes to NATS JetStream, which drops events it already
+// stored by their Nats-Msg-Id.
+type natsPublisher struct {
+	conn   *nats.Conn
+	stream nats.JetStreamContext
+}
+
+func openNats(address string) (publisher, error) {
+	conn, err := nats.Connect(address, nats.Name("scrapers "+site.Name), nats.Timeout(10*time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.JetStream(nats.MaxWait(30 * time.Second))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := stream.StreamInfo(busOptions.Stream); err == nats.ErrStreamNotFound {
+		_, err = stream.AddStream(&nats.StreamConfig{Name: busOptions.Stream, Subjects: []string{busOptions.Prefix + ".>"}})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	} else if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &natsPublisher{conn: conn, stream: stream}, nil
+}
+
+func (publisher *natsPublisher) publish(event busEvent) error {
+	_, err := publisher.stream.Publish(event.Subject, event.Payload, nats.MsgId(event.Id))
+
+	return err
+}
+
+func (publisher *natsPublisher) close() error {
+	return publisher.conn.Drain()
+}
+
+// kafkaPublisher publishes to Kafka, keyed by canonical id so that the
+// events of a document stay in order.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func openKafka(brokers string) (publisher, error) {
+	addresses := strings.Split(brokers, ",")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := kafka.DialContext(ctx, "tcp", addresses[0])
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(addresses...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}}, nil
+}
+
+func (publisher *kafkaPublisher) publish(event busEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return publisher.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   event.Subject,
+		Key:     []byte(event.Key),
+		Value:   event.Payload,
+		Headers: []kafka.Header{{Key: "event-id", Value: []byte(event.Id)}},
+	})
+}
+
+func (publisher *kafkaPublisher) close() error {
+	return publisher.writer.Close()
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestBusEventsAgree(t *testing.T) {
+	site = Site{Name: "cointelegraph.com", Type: "article"}
+	busOptions = BusOptions{Prefix: "scrapers"}
+
+	doc := tableDoc{"https://cointelegraph.com/news/1", "one"}
+	record := Record{Id: doc.link, Name: doc.title, Doc: doc, Saved: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+
+	if subject := busSubject(record); subject != "scrapers.cointelegraph_com.article" {
+		t.Errorf("got subject %q", subject)
+	}
+
+	busOptions.Format = "json"
+	payload, err := encodeEvent("created", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := struct {
+		SavedAt int64 `json:"saved_at"`
+	}{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("saved_at is not in Unix milliseconds: %v", err)
+	}
+
+	busOptions.Format = "protobuf"
+	message, err := encodeEvent("created", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedAt := int64(0)
+	for len(message) > 0 {
+		number, kind, n := protowire.ConsumeTag(message)
+		message = message[n:]
+
+		if number == 6 && kind == protowire.VarintType {
+			value, _ := protowire.ConsumeVarint(message)
+			savedAt = int64(value)
+		}
+
+		message = message[protowire.ConsumeFieldValue(number, kind, message):]
+	}
+
+	if event.SavedAt != record.Saved.UnixMilli() || savedAt != event.SavedAt {
+		t.Errorf("got saved_at %d in JSON and %d in protobuf, want %d", event.SavedAt, savedAt, record.Saved.UnixMilli())
+	}
+}
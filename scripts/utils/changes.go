@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// changes remembers the checksum of every document a sink delivered, to
+// tell new and changed documents from those delivered before.
+type changes struct {
+	path string
+	sums map[string]string
+}
+
+// loadChanges reads the checksums a previous run saved to path.
+func loadChanges(path string) (*changes, error) {
+	state := &changes{path: path, sums: map[string]string{}}
+
+	file, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(file, &state.sums); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	return state, nil
+}
+
+// kind returns "created" for a document never delivered, "updated" for one
+// that changed since it was delivered, and "" for one that did not.
+func (state *changes) kind(record Record) string {
+	previous, seen := state.sums[newDocumentRow(record).id]
+
+	switch {
+	case !seen:
+		return "created"
+	case previous != checksum(record.Json):
+		return "updated"
+	default:
+		return ""
+	}
+}
+
+// mark remembers record as delivered.
+func (state *changes) mark(record Record) {
+	state.sums[newDocumentRow(record).id] = checksum(record.Json)
+}
+
+func (state *changes) save() error {
+	file, err := json.Marshal(state.sums)
+	if err != nil {
+		return err
+	}
+
+	return replace(state.path, file)
+}
@@ -0,0 +1,742 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	mdhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// markdownParser reads content as CommonMark with the GitHub extensions:
+// tables, strikethrough, task lists and bare links.
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// htmlTag matches the tags of HTML content, which Markdown content only
+// has in code.
+var htmlTag = regexp.MustCompile(`(?i)(^|[^\\])</?[a-z][a-z0-9]*(\s[^<>]*)?/?>`)
+
+// markdownCode matches the code blocks and code spans of Markdown.
+var markdownCode = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// blockTags are the elements converted to Markdown blocks rather than
+// inline text.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "center": true, "dd": true,
+	"details": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "tfoot": true, "thead": true, "ul": true,
+}
+
+// skippedTags are the elements whose content is not part of the text.
+var skippedTags = map[string]bool{
+	"button": true, "embed": true, "head": true, "iframe": true, "input": true, "noscript": true, "object": true,
+	"script": true, "select": true, "style": true, "svg": true, "template": true, "textarea": true,
+}
+
+// isHTML reports whether content is HTML rather than Markdown or plain text.
+func isHTML(content string) bool {
+	return htmlTag.MatchString(markdownCode.ReplaceAllString(content, ""))
+}
+
+// ElementMarkdown returns the content of the element as Markdown, without
+// the descendants matching the exclude selectors, with its links resolved
+// against the page.
+func ElementMarkdown(h *colly.HTMLElement, exclude ...string) string {
+	return SelectionMarkdown(h.DOM, h.Request.URL.String(), exclude...)
+}
+
+// SelectionMarkdown returns the first element of the selection as
+// Markdown, without the descendants matching the exclude
+// selectors, with its links resolved against base.
+func SelectionMarkdown(s *goquery.Selection, base string, exclude ...string) string {
+	if len(exclude) > 0 {
+		// the selection is part of the page other callbacks still read
+		s = s.First().Clone()
+		s.Find(strings.Join(exclude, ", ")).Remove()
+	}
+
+	content, err := goquery.OuterHtml(s.First())
+	if err != nil {
+		return ""
+	}
+
+	return HTMLMarkdown(content, base)
+}
+
+// HTMLMarkdown returns the HTML content as CommonMark, with GFM tables and
+// strikethrough, keeping its headings, paragraphs, lists, links, quotes,
+// code blocks and images. Links and images are resolved against base, the
+// URL of the page, when it is given. Content that is Markdown or plain
+// text already is returned as it is.
+func HTMLMarkdown(content string, base string) string {
+	if !isHTML(content) {
+		return strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	}
+
+	context := &xhtml.Node{Type: xhtml.ElementNode, Data: "div", DataAtom: atom.Div}
+
+	nodes, err := xhtml.ParseFragment(strings.NewReader(content), context)
+	if err != nil {
+		return strings.Join(strings.Fields(content), " ")
+	}
+
+	converter := markdownConverter{}
+	if base != "" {
+		converter.base, _ = url.Parse(base)
+	}
+
+	return strings.Join(converter.blocks(nodes), "\n\n")
+}
+
+// markdownConverter converts HTML nodes to Markdown.
+type markdownConverter struct {
+	base *url.URL
+}
+
+// blocks returns the Markdown blocks of nodes, with the inline nodes
+// between block elements as paragraphs.
+func (converter markdownConverter) blocks(nodes []*xhtml.Node) []string {
+	blocks := make([]string, 0)
+	var inline strings.Builder
+
+	flush := func() {
+		if paragraph := markdownParagraph(inline.String()); paragraph != "" {
+			blocks = append(blocks, paragraph)
+		}
+		inline.Reset()
+	}
+
+	for _, node := range nodes {
+		if node.Type == xhtml.ElementNode && isBlock(node) {
+			flush()
+			if block := converter.block(node); block != "" {
+				blocks = append(blocks, block)
+			}
+		} else {
+			inline.WriteString(converter.inline(node))
+		}
+	}
+
+	flush()
+
+	return blocks
+}
+
+func children(node *xhtml.Node) []*xhtml.Node {
+	nodes := make([]*xhtml.Node, 0)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, child)
+	}
+
+	return nodes
+}
+
+func isBlock(node *xhtml.Node) bool {
+	return blockTags[node.Data] || skippedTags[node.Data]
+}
+
+// block returns the Markdown of the block element node.
+func (converter markdownConverter) block(node *xhtml.Node) string {
+	if skippedTags[node.Data] {
+		return ""
+	}
+
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		// hard breaks become spaces before the whitespace is collapsed,
+		// which would leave the backslash of the break in the heading
+		heading := strings.ReplaceAll(markdownParagraph(converter.inlines(children(node))), `\`+"\n", " ")
+		heading = strings.Join(strings.Fields(heading), " ")
+		if heading == "" {
+			return ""
+		}
+		level, _ := strconv.Atoi(node.Data[1:])
+		return strings.Repeat("#", level) + " " + heading
+	case "p", "dt", "dd", "figcaption", "summary":
+		return strings.Join(converter.blocks(children(node)), "\n\n")
+	case "blockquote":
+		return prefixLines(strings.Join(converter.blocks(children(node)), "\n\n"), "> ", ">")
+	case "ul", "ol":
+		return converter.list(node)
+	case "li":
+		return converter.listItem(node, "- ")
+	case "pre":
+		return codeBlock(node)
+	case "hr":
+		return "---"
+	case "table":
+		return converter.table(node)
+	}
+
+	return strings.Join(converter.blocks(children(node)), "\n\n")
+}
+
+// inlines returns the Markdown of the inline nodes.
+func (converter markdownConverter) inlines(nodes []*xhtml.Node) string {
+	var out strings.Builder
+	for _, node := range nodes {
+		out.WriteString(converter.inline(node))
+	}
+
+	return out.String()
+}
+
+// inline returns the Markdown of the inline node, with its runs of
+// whitespace collapsed.
+func (converter markdownConverter) inline(node *xhtml.Node) string {
+	switch node.Type {
+	case xhtml.TextNode:
+		return escapeMarkdown(collapseSpaces(node.Data))
+	case xhtml.ElementNode:
+	default:
+		return ""
+	}
+
+	if skippedTags[node.Data] {
+		return ""
+	}
+
+	switch node.Data {
+	case "br":
+		return "\\\n"
+	case "strong", "b":
+		return emphasis(converter.inlines(children(node)), "**")
+	case "em", "i", "cite":
+		return emphasis(converter.inlines(children(node)), "*")
+	case "del", "s", "strike":
+		return emphasis(converter.inlines(children(node)), "~~")
+	case "code", "kbd", "samp", "tt":
+		return codeSpan(collapseSpaces(textContent(node)))
+	case "a":
+		label := converter.inlines(children(node))
+		href := converter.resolve(attr(node, "href"))
+		if strings.TrimSpace(label) == "" || href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return label
+		}
+		leading, trimmed, trailing := splitSpaces(label)
+		return leading + "[" + trimmed + "](" + markdownDestination(href) + ")" + trailing
+	case "img":
+		src := imageSource(node)
+		if src == "" {
+			return ""
+		}
+		alt := strings.NewReplacer("[", "", "]", "").Replace(collapseSpaces(attr(node, "alt")))
+		return "![" + escapeMarkdown(alt) + "](" + markdownDestination(converter.resolve(src)) + ")"
+	}
+
+	if isBlock(node) {
+		// blocks in inline elements, such as the paragraphs of a linked
+		// card, are run into the text
+		return " " + strings.Join(converter.blocks(children(node)), " ") + " "
+	}
+
+	return converter.inlines(children(node))
+}
+
+// list returns the Markdown of the ul or ol element node.
+func (converter markdownConverter) list(node *xhtml.Node) string {
+	number, err := strconv.Atoi(attr(node, "start"))
+	if err != nil {
+		number = 1
+	}
+
+	items := make([]string, 0)
+	for _, child := range children(node) {
+		if child.Type == xhtml.TextNode && strings.TrimSpace(child.Data) == "" || child.Type != xhtml.ElementNode && child.Type != xhtml.TextNode {
+			continue
+		}
+
+		marker := "- "
+		if node.Data == "ol" {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		if item := converter.listItem(child, marker); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// listItem returns the Markdown of the list item node, with marker in front
+// of its first line and its other lines indented to match.
+func (converter markdownConverter) listItem(node *xhtml.Node, marker string) string {
+	var blocks []string
+	if node.Type == xhtml.ElementNode && node.Data == "li" {
+		blocks = converter.blocks(children(node))
+	} else {
+		blocks = converter.blocks([]*xhtml.Node{node})
+	}
+
+	// nested lists follow the text of the item directly, so the list
+	// stays tight
+	content := ""
+	for i, block := range blocks {
+		if i > 0 && listStart.MatchString(block) {
+			content += "\n" + block
+		} else if i > 0 {
+			content += "\n\n" + block
+		} else {
+			content = block
+		}
+	}
+
+	if content == "" {
+		return ""
+	}
+
+	indent := strings.Repeat(" ", len(marker))
+
+	return marker + strings.TrimPrefix(prefixLines(content, indent, ""), indent)
+}
+
+// table returns the table element node as a GFM table, with its first row as
+// the header.
+func (converter markdownConverter) table(node *xhtml.Node) string {
+	rows := make([][]string, 0)
+	columns := 0
+
+	var walk func(node *xhtml.Node)
+	walk = func(node *xhtml.Node) {
+		for _, child := range children(node) {
+			if child.Type != xhtml.ElementNode {
+				continue
+			}
+
+			switch child.Data {
+			case "tr":
+				row := make([]string, 0)
+				for _, cell := range children(child) {
+					if cell.Type == xhtml.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := strings.Join(converter.blocks(children(cell)), " ")
+						text = strings.ReplaceAll(strings.ReplaceAll(text, "\\\n", " "), "\n", " ")
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(row) > columns {
+					columns = len(row)
+				}
+				rows = append(rows, row)
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+
+	if len(rows) == 0 || columns == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	writeRow := func(row []string) {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		out.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+
+	writeRow(rows[0])
+	out.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	caption := ""
+	for _, child := range children(node) {
+		if child.Type == xhtml.ElementNode && child.Data == "caption" {
+			caption = markdownParagraph(converter.inlines(children(child)))
+		}
+	}
+
+	if caption != "" {
+		return caption + "\n\n" + strings.TrimSuffix(out.String(), "\n")
+	}
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// codeBlock returns the pre element node as a fenced code block, in the
+// language its class names, if any.
+func codeBlock(node *xhtml.Node) string {
+	code := strings.Trim(textContent(node), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+
+	language := codeLanguage(node)
+	for child := node.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == xhtml.ElementNode && child.Data == "code" {
+			language = codeLanguage(child)
+		}
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(node *xhtml.Node) string {
+	for _, class := range strings.Fields(attr(node, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+
+	return ""
+}
+
+// codeSpan returns code as a code span, fenced by more backticks than it
+// has in a row.
+func codeSpan(code string) string {
+	if strings.TrimSpace(code) == "" {
+		return code
+	}
+
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+
+	return fence + code + fence
+}
+
+// emphasis wraps text in the delimiter, keeping the spaces around it outside
+// so that the delimiters count as emphasis.
+func emphasis(text string, delimiter string) string {
+	leading, trimmed, trailing := splitSpaces(text)
+	if trimmed == "" {
+		return text
+	}
+
+	return leading + delimiter + trimmed + delimiter + trailing
+}
+
+func splitSpaces(text string) (string, string, string) {
+	trimmed := strings.TrimLeft(text, " ")
+	leading := text[:len(text)-len(trimmed)]
+	trimmed = strings.TrimRight(trimmed, " ")
+	trailing := text[len(leading)+len(trimmed):]
+
+	return leading, trimmed, trailing
+}
+
+// markdownParagraph returns the inline Markdown as a paragraph: with single
+// spaces between words, no space around line breaks, and the characters
+// that would start another block at the start of its lines escaped.
+func markdownParagraph(inline string) string {
+	lines := strings.Split(inline, "\\\n")
+
+	paragraph := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(strings.Join(strings.Fields(line), " "))
+		if line == "" {
+			continue
+		}
+		line = orderedStart.ReplaceAllString(line, `$1\$2`)
+		paragraph = append(paragraph, blockStart.ReplaceAllString(line, `\$0`))
+	}
+
+	return strings.Join(paragraph, "\\\n")
+}
+
+// listStart matches the first line of a Markdown list.
+var listStart = regexp.MustCompile(`^(- |\d{1,9}\. )`)
+
+// orderedStart matches the start of lines that would be read as an ordered
+// list item rather than text, e.g. "2024. was the year", but not "2024-01-15".
+var orderedStart = regexp.MustCompile(`^(\d{1,9})([.)](?:\s|$))`)
+
+// blockStart matches the start of lines that would be read as a heading,
+// bullet list item, quote or rule rather than text.
+var blockStart = regexp.MustCompile(`^[#>+\-=]`)
+
+// markdownEscaped are the characters escaped in Markdown text.
+var markdownEscaped = "\\`*[]<~"
+
+// escapeMarkdown escapes the characters of text that Markdown reads as
+// formatting: underscores only next to spaces or punctuation, as in
+// snake_case they are text, and ampersands only before entity names.
+func escapeMarkdown(text string) string {
+	var out strings.Builder
+	runes := []rune(text)
+
+	for i, r := range runes {
+		switch {
+		case strings.ContainsRune(markdownEscaped, r):
+			out.WriteRune('\\')
+		case r == '_':
+			if i == 0 || i == len(runes)-1 || !isWordRune(runes[i-1]) || !isWordRune(runes[i+1]) {
+				out.WriteRune('\\')
+			}
+		case r == '&':
+			if entityStart.MatchString(string(runes[i:])) {
+				out.WriteRune('\\')
+			}
+		}
+
+		out.WriteRune(r)
+	}
+
+	return out.String()
+}
+
+var entityStart = regexp.MustCompile(`^&#?[a-zA-Z0-9]+;`)
+
+func isWordRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127
+}
+
+// collapseSpaces returns text with its runs of whitespace replaced with
+// single spaces.
+func collapseSpaces(text string) string {
+	var out strings.Builder
+	space := false
+
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				out.WriteRune(' ')
+			}
+			space = true
+			continue
+		}
+
+		out.WriteRune(r)
+		space = false
+	}
+
+	return out.String()
+}
+
+// markdownDestination returns link as the destination of a Markdown link or
+// image.
+func markdownDestination(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", `\(`, ")", `\)`, "<", "%3C", ">", "%3E").Replace(link)
+}
+
+// resolve returns the link relative to the page as an absolute URL.
+func (converter markdownConverter) resolve(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || converter.base == nil {
+		return link
+	}
+
+	resolved, err := converter.base.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	return resolved.String()
+}
+
+// imageSource returns the source of the img element node, taking the
+// attributes of lazy loaded images into account.
+func imageSource(node *xhtml.Node) string {
+	for _, key := range []string{"src", "data-src", "data-lazy-src", "data-original"} {
+		if src := strings.TrimSpace(attr(node, key)); src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+
+	if srcset := strings.Fields(attr(node, "srcset")); len(srcset) > 0 {
+		return srcset[0]
+	}
+
+	return ""
+}
+
+func attr(node *xhtml.Node, key string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Key == key {
+			return attribute.Val
+		}
+	}
+
+	return ""
+}
+
+// textContent returns the text of node and its descendants as it is.
+func textContent(node *xhtml.Node) string {
+	if node.Type == xhtml.TextNode {
+		return node.Data
+	}
+
+	var out strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xhtml.ElementNode && child.Data == "br" {
+			out.WriteString("\n")
+			continue
+		}
+		out.WriteString(textContent(child))
+	}
+
+	return out.String()
+}
+
+// prefixLines returns text with prefix in front of every line, or empty in
+// front of empty lines.
+func prefixLines(text string, prefix string, empty string) string {
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = empty
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// markdownImage matches the images of Markdown content as Markdown
+// converts them.
+var markdownImage = regexp.MustCompile(`!\[((?:\\.|[^\\\]])*)\]\(((?:\\.|[^\\\s)])+)\)`)
+
+// replaceImages returns the Markdown content with the source of every image
+// replaced with the one image returns for it.
+func replaceImages(content string, image func(src string) string) string {
+	return markdownImage.ReplaceAllStringFunc(content, func(match string) string {
+		parts := markdownImage.FindStringSubmatch(match)
+		src := string(util.UnescapePunctuations([]byte(parts[2])))
+
+		return "![" + parts[1] + "](" + markdownDestination(image(src)) + ")"
+	})
+}
+
+// markdownHTML returns the Markdown content rendered as HTML.
+func markdownHTML(content string) string {
+	var out bytes.Buffer
+	if err := markdownParser.Convert([]byte(content), &out); err != nil {
+		return ""
+	}
+
+	return out.String()
+}
+
+// markdownBlocks returns the text of the paragraphs, headings, list items,
+// table rows, code blocks and other leaf blocks of the Markdown content,
+// with runs of whitespace collapsed to single spaces.
+func markdownBlocks(content string) []string {
+	source := []byte(content)
+	document := markdownParser.Parser().Parse(text.NewReader(source))
+
+	blocks := make([]string, 0)
+	add := func(block string) {
+		if block = strings.Join(strings.Fields(block), " "); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := node.(type) {
+		case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+			add(inlineText(node, source))
+			return ast.WalkSkipChildren, nil
+		case *east.TableHeader, *east.TableRow:
+			cells := make([]string, 0)
+			for cell := node.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, inlineText(cell, source))
+			}
+			add(strings.Join(cells, " "))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			var code strings.Builder
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				code.Write(segment.Value(source))
+			}
+			add(code.String())
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return blocks
+}
+
+// inlineText returns the text of the inline children of node, without
+// images and raw HTML.
+func inlineText(node ast.Node, source []byte) string {
+	var out strings.Builder
+
+	ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch child := child.(type) {
+		case *ast.Image, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			value := child.Segment.Value(source)
+			if _, ok := child.Parent().(*ast.CodeSpan); !ok {
+				// the writer of the HTML renderer resolves escapes and
+				// entities the way CommonMark does
+				var resolved bytes.Buffer
+				writer := bufio.NewWriter(&resolved)
+				mdhtml.DefaultWriter.Write(writer, value)
+				writer.Flush()
+				value = []byte(html.UnescapeString(resolved.String()))
+			}
+			out.Write(value)
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				out.WriteString(" ")
+			}
+		case *ast.String:
+			out.Write(child.Value)
+		case *ast.AutoLink:
+			out.Write(child.URL(source))
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return out.String()
+}
+
+// markdownText returns the Markdown content as plain text, with runs of
+// whitespace collapsed to single spaces.
+func markdownText(content string) string {
+	return strings.Join(markdownBlocks(content), " ")
+}
@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestHTMLMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"paragraphs", "<p>One\n  two</p><p>Three</p>", "One two\n\nThree"},
+		{"heading", "<h2>Title</h2><p>Text</p>", "## Title\n\nText"},
+		{"heading with a break", "<h2>Line one<br>Line two</h2>", "## Line one Line two"},
+		{"hard break", "<p>Line one<br>Line two</p>", "Line one\\\nLine two"},
+		{"emphasis", "<p><strong>bold</strong> and <em>italic</em></p>", "**bold** and *italic*"},
+		{"link", `<p><a href="/news/1">news</a></p>`, "[news](https://example.com/news/1)"},
+		{"image", `<p><img src="/cover.png" alt="cover"></p>`, "![cover](https://example.com/cover.png)"},
+		{"bullet list", "<ul><li>one</li><li>two</li></ul>", "- one\n- two"},
+		{"ordered list", "<ol><li>one</li><li>two</li></ol>", "1. one\n2. two"},
+		{"quote", "<blockquote><p>quoted</p></blockquote>", "> quoted"},
+		{"code block", `<pre><code class="language-go">x := 1</code></pre>`, "```go\nx := 1\n```"},
+		{"code span", "<p>run <code>go test</code></p>", "run `go test`"},
+		{"escaped text", "<p>a *b* [c]</p>", `a \*b\* \[c\]`},
+		{"snake case", "<p>snake_case</p>", "snake_case"},
+		{"date", "<p>2024-01-15 was the day</p>", "2024-01-15 was the day"},
+		{"number and plus", "<p>100+ coins</p>", "100+ coins"},
+		{"ordered list start", "<p>2024. was the year</p>", `2024\. was the year`},
+		{"heading start", "<p># not a heading</p>", `\# not a heading`},
+		{"bullet start", "<p>- not a list</p>", `\- not a list`},
+		{"quote start", "<p>&gt; not a quote</p>", `\> not a quote`},
+		{"skipped elements", "<p>text</p><script>alert(1)</script>", "text"},
+		{"markdown", "Already **Markdown**", "Already **Markdown**"},
+	}
+
+	for _, test := range tests {
+		if got := HTMLMarkdown(test.html, "https://example.com/news/"); got != test.want {
+			t.Errorf("%s: HTMLMarkdown(%q) = %q, want %q", test.name, test.html, got, test.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"<h2>Title</h2><p>Some <strong>bold</strong> text</p>", "Title Some bold text"},
+		{"<p>a *b* and <a href=\"/x\">link</a></p>", "a *b* and link"},
+		{"<ul><li>one</li><li>two</li></ul>", "one two"},
+		{"plain  text", "plain text"},
+	}
+
+	for _, test := range tests {
+		if got := PlainText(test.content); got != test.want {
+			t.Errorf("PlainText(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
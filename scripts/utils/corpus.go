@@ -0,0 +1,283 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fields documents name differently across sites, most common first. Sites
+// renaming a field save it under one of the other names.
+var (
+	titleFields    = []string{"title", "term", "name"}
+	linkFields     = []string{"link", "url", "social"}
+	summaryFields  = []string{"description", "excerpt", "short_desc"}
+	categoryFields = []string{"category", "categories", "tags"}
+	authorFields   = []string{"author", "authors"}
+	entryDates     = []string{"published", "created_at", "date", "updated"}
+)
+
+// Entry is a document the files sink saved, as read back from the output
+// directory.
+type Entry struct {
+	// Site is the site the document was scraped from.
+	Site string
+	// Type is the document type, e.g. article or glossary.
+	Type string
+	// Id is the canonical id of the document, its URL or GUID.
+	Id string
+	// Path is the JSON file of the document, relative to the output root.
+	Path string
+	// Saved is when the document was saved.
+	Saved time.Time
+	// Fields are the fields of the JSON file.
+	Fields map[string]interface{}
+	// Separators are the separators of the list fields of the JSON file,
+	// as published in the schema of the document type.
+	Separators map[string]string
+}
+
+// LoadCorpus reads the documents the files sink saved under root, of the
+// given sites or of all sites when none are given, newest first.
+func LoadCorpus(root string, sites []string) ([]Entry, error) {
+	wanted := map[string]bool{}
+	for _, name := range sites {
+		wanted[name] = true
+	}
+
+	entries := make([]Entry, 0)
+
+	schemaPaths, err := newestSchemas(root)
+	if err != nil {
+		return nil, err
+	}
+	separators := map[string]map[string]string{}
+
+	err = filepath.WalkDir(root, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, ".done") {
+			return nil
+		}
+
+		entry, ok, err := loadEntry(root, path)
+		if err != nil {
+			return err
+		}
+
+		if ok && (len(wanted) == 0 || wanted[entry.Site]) {
+			key := entry.Site + "/" + entry.Type
+			if _, read := separators[key]; !read {
+				separators[key] = schemaSeparators(root, schemaPaths[key])
+			}
+			entry.Separators = separators[key]
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date().After(entries[j].Date())
+	})
+
+	return entries, nil
+}
+
+// loadEntry reads the document of the marker at path, reporting false for
+// markers without a JSON file.
+func loadEntry(root string, path string) (Entry, bool, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	done := marker{}
+	if err := json.Unmarshal(file, &done); err != nil {
+		return Entry{}, false, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	for relative := range done.Files {
+		if !strings.HasSuffix(relative, ".json") {
+			continue
+		}
+
+		document := filepath.Join(filepath.Dir(path), filepath.FromSlash(relative))
+
+		body, err := os.ReadFile(document)
+		if err != nil {
+			return Entry{}, false, err
+		}
+
+		entry := Entry{Site: done.Site, Type: done.Type, Id: done.Id, Saved: done.Saved}
+		if err := json.Unmarshal(body, &entry.Fields); err != nil {
+			return Entry{}, false, fmt.Errorf("unable to read %s: %v", document, err)
+		}
+
+		if entry.Path, err = filepath.Rel(root, document); err != nil {
+			return Entry{}, false, err
+		}
+		entry.Path = filepath.ToSlash(entry.Path)
+
+		// markers written before they named the site sit under it
+		if entry.Site == "" {
+			entry.Site = strings.Split(entry.Path, "/")[0]
+		}
+
+		// documents saved before every document had an id are told apart by
+		// their path
+		if entry.Id == "" {
+			entry.Id = entry.Path
+		}
+
+		return entry, true, nil
+	}
+
+	return Entry{}, false, nil
+}
+
+// schemaSeparators returns the separators of the list fields of the schema
+// at path under root, published as their x-separator, none when there is no
+// schema.
+func schemaSeparators(root string, path string) map[string]string {
+	separators := map[string]string{}
+	if path == "" {
+		return separators
+	}
+
+	body, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		return separators
+	}
+
+	schema := struct {
+		Properties map[string]struct {
+			Separator string `json:"x-separator"`
+		} `json:"properties"`
+	}{}
+	if err := json.Unmarshal(body, &schema); err != nil {
+		return separators
+	}
+
+	for name, property := range schema.Properties {
+		if property.Separator != "" {
+			separators[name] = property.Separator
+		}
+	}
+
+	return separators
+}
+
+// List returns the items of the first of the fields the entry has a value
+// for, split on the separator of the field.
+func (entry Entry) List(fields ...string) []string {
+	for _, field := range fields {
+		if value := entry.Text(field); value != "" {
+			return splitItems(value, entry.Separators[field])
+		}
+	}
+
+	return []string{}
+}
+
+// Text returns the first of the fields the entry has a value for.
+func (entry Entry) Text(fields ...string) string {
+	for _, field := range fields {
+		switch value := entry.Fields[field].(type) {
+		case nil:
+		case string:
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		case map[string]interface{}, []interface{}:
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+
+	return ""
+}
+
+// Title returns the title of the entry.
+func (entry Entry) Title() string {
+	return entry.Text(titleFields...)
+}
+
+// Link returns the URL of the entry, if it has one.
+func (entry Entry) Link() string {
+	if link := entry.Text(linkFields...); strings.HasPrefix(link, "http") {
+		return link
+	}
+
+	if strings.HasPrefix(entry.Id, "http") {
+		return entry.Id
+	}
+
+	return ""
+}
+
+// Summary returns the description of the entry.
+func (entry Entry) Summary() string {
+	return entry.Text(summaryFields...)
+}
+
+// Content returns the body of the entry.
+func (entry Entry) Content() string {
+	return entry.Text("content")
+}
+
+// Categories returns the categories and tags of the entry.
+func (entry Entry) Categories() []string {
+	categories := make([]string, 0)
+	for _, field := range categoryFields {
+		categories = append(categories, entry.List(field)...)
+	}
+
+	return categories
+}
+
+// Authors returns the authors of the entry.
+func (entry Entry) Authors() []string {
+	authors := make([]string, 0)
+	for _, field := range authorFields {
+		authors = append(authors, entry.List(field)...)
+	}
+
+	return authors
+}
+
+// Published returns the publication date of the entry.
+func (entry Entry) Published() (time.Time, bool) {
+	for _, field := range entryDates {
+		if date, ok := parseDate(entry.Text(field)); ok {
+			return date.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Date returns the publication date of the entry, or when it was saved for
+// entries without one.
+func (entry Entry) Date() time.Time {
+	if date, ok := entry.Published(); ok {
+		return date
+	}
+
+	return entry.Saved.UTC()
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DriftExitCode is returned by a run whose documents fell below the site's
+// coverage expectations, which usually means the site changed its markup.
+const DriftExitCode = 3
+
+type coverageReport struct {
+	documents int
+	filled    map[string]int
+}
+
+var coverage = newCoverageReport()
+
+func newCoverageReport() *coverageReport {
+	return &coverageReport{filled: map[string]int{}}
+}
+
+// Observe records which fields of an extracted document are non-empty.
+func Observe(doc Document) {
+	coverage.Observe(doc)
+}
+
+func (report *coverageReport) Observe(doc Document) {
+	headers := doc.GetHeaders()
+	values := doc.GetValues()
+
+	report.documents++
+
+	for i, header := range headers {
+		if i < len(values) && strings.TrimSpace(values[i]) != "" {
+			report.filled[header]++
+		}
+	}
+}
+
+func (report *coverageReport) Ratio(field string) float64 {
+	if report.documents == 0 {
+		return 0
+	}
+
+	return float64(report.filled[field]) / float64(report.documents)
+}
+
+// Check prints a drift alert for every expectation of the site that was not
+// met and returns the fields that drifted.
+func (report *coverageReport) Check(s Site) map[string]bool {
+	drifted := map[string]bool{}
+
+	if len(s.Coverage) == 0 {
+		return drifted
+	}
+
+	if report.documents == 0 {
+		fmt.Fprintf(os.Stderr, "DRIFT ALERT %s: no documents were extracted\n", s.Name)
+
+		for field := range s.Coverage {
+			drifted[field] = true
+		}
+
+		return drifted
+	}
+
+	fields := make([]string, 0)
+	for field := range s.Coverage {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		expected := s.Coverage[field]
+		actual := report.Ratio(field)
+
+		if actual < expected {
+			fmt.Fprintf(os.Stderr, "DRIFT ALERT %s: %q is non-empty in %d of %d documents (%.1f%%), expected at least %.1f%%\n",
+				s.Name, field, report.filled[field], report.documents, actual*100, expected*100)
+			drifted[field] = true
+		}
+	}
+
+	return drifted
+}
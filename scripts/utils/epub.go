@@ -0,0 +1,472 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Book is a guide assembled from the pages of a site, written as EPUB 3.
+type Book struct {
+	// Title is the title of the book.
+	Title string
+	// Source is the URL of the site the pages were scraped from.
+	Source string
+	// Language is the language of the pages, e.g. en.
+	Language string
+	// Scraped is when the newest page was scraped.
+	Scraped time.Time
+	// Chapters are the pages, in reading order.
+	Chapters []Chapter
+	// Fetch downloads the images of the pages to embed them. Images are
+	// dropped, keeping their alternative text, when it is nil or fails.
+	Fetch func(src string) ([]byte, error)
+}
+
+// Chapter is a page of a book.
+type Chapter struct {
+	// Title is the title of the page.
+	Title string
+	// Link is the URL of the page, which relative image sources resolve
+	// against.
+	Link string
+	// Section groups the chapters listed under it in the table of
+	// contents, if set.
+	Section string
+	// Content is the body of the page, as HTML or plain text.
+	Content string
+}
+
+// imageTypes are the extensions of the image types EPUB readers and
+// browsers support.
+var imageTypes = map[string]string{
+	"image/gif":     ".gif",
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/svg+xml": ".svg",
+	"image/webp":    ".webp",
+}
+
+// voidElements are written as empty XHTML elements.
+var voidElements = map[string]bool{
+	"area": true, "br": true, "col": true, "hr": true, "img": true, "source": true, "track": true, "wbr": true,
+}
+
+// epubImage is an image embedded in the book.
+type epubImage struct {
+	Href string
+	Type string
+	body []byte
+}
+
+// epubFile is a chapter of the book, as written to it.
+type epubFile struct {
+	Href    string
+	Title   string
+	Section string
+	Body    string
+}
+
+// FetchImage downloads the image at src.
+func FetchImage(src string) ([]byte, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+
+	response, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", response.Status)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
+// imageType returns the type of the image at path with body, and its
+// extension when it is a type readers support.
+func imageType(path string, body []byte) (string, string) {
+	contentType := http.DetectContentType(body)
+	if strings.HasSuffix(strings.ToLower(path), ".svg") && strings.Contains(contentType, "xml") {
+		contentType = "image/svg+xml"
+	}
+
+	return contentType, imageTypes[contentType]
+}
+
+// Write writes the book as EPUB 3 to w.
+func (book Book) Write(w io.Writer) error {
+	images := map[string]*epubImage{}
+	order := make([]*epubImage, 0)
+
+	embed := func(base string, src string) string {
+		resolved, err := url.Parse(base)
+		if err == nil {
+			resolved, err = resolved.Parse(src)
+		}
+		if err != nil || book.Fetch == nil {
+			return ""
+		}
+
+		if image, ok := images[resolved.String()]; ok {
+			return image.Href
+		}
+
+		image := &epubImage{}
+		images[resolved.String()] = image
+
+		body, err := book.Fetch(resolved.String())
+		if err != nil {
+			log.Printf("Unable to embed image %s: %v", resolved, err)
+			return ""
+		}
+
+		var ext string
+		if image.Type, ext = imageType(resolved.Path, body); ext == "" {
+			log.Printf("Unable to embed image %s: unsupported type %s", resolved, image.Type)
+			return ""
+		}
+
+		sum := sha1.Sum([]byte(resolved.String()))
+		image.Href = "images/" + hex.EncodeToString(sum[:6]) + ext
+		image.body = body
+		order = append(order, image)
+
+		return image.Href
+	}
+
+	files := make([]epubFile, 0, len(book.Chapters))
+	for i, chapter := range book.Chapters {
+		body, err := chapterBody(chapter, func(src string) string {
+			return embed(chapter.Link, src)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to convert %s: %v", chapter.Link, err)
+		}
+
+		files = append(files, epubFile{
+			Href:    fmt.Sprintf("chapter-%04d.xhtml", i+1),
+			Title:   chapter.Title,
+			Section: chapter.Section,
+			Body:    body,
+		})
+	}
+
+	archive := zip.NewWriter(w)
+
+	modified := time.Now().UTC()
+
+	// the mimetype comes first and uncompressed, so readers can sniff it
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	data := epubData{Book: book, Id: book.identifier(), Files: files, Images: order, Modified: modified}
+	if data.Language == "" {
+		data.Language = "en"
+	}
+
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/title.xhtml", "OEBPS/style.css"} {
+		if err := writeEntry(archive, name, path.Base(name), data); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		data.File = file
+
+		if err := writeEntry(archive, "OEBPS/"+file.Href, "chapter.xhtml", data); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range order {
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + image.Href, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(image.body); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// identifier returns the unique id of the book, a UUID derived from its
+// source so new editions replace the old ones in readers.
+func (book Book) identifier() string {
+	sum := sha1.Sum([]byte(book.Source + "\n" + book.Title))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+
+	id := hex.EncodeToString(sum[:16])
+
+	return "urn:uuid:" + id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
+
+// Sections returns the chapters of the table of contents, grouped by
+// their sections in reading order.
+func (data epubData) Sections() []epubSection {
+	sections := make([]epubSection, 0)
+	for _, file := range data.Files {
+		if len(sections) == 0 || sections[len(sections)-1].Title != file.Section {
+			sections = append(sections, epubSection{Title: file.Section})
+		}
+
+		last := &sections[len(sections)-1]
+		last.Files = append(last.Files, file)
+	}
+
+	return sections
+}
+
+// epubData is what the files of a book are rendered with.
+type epubData struct {
+	Book
+	Id       string
+	Files    []epubFile
+	Images   []*epubImage
+	Modified time.Time
+	// File is the chapter being rendered.
+	File epubFile
+}
+
+type epubSection struct {
+	Title string
+	Files []epubFile
+}
+
+// writeEntry writes the file name of the book, rendered from the template
+// page with data.
+func writeEntry(archive *zip.Writer, name string, page string, data epubData) error {
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: data.Modified})
+	if err != nil {
+		return err
+	}
+
+	return epubTemplates.ExecuteTemplate(writer, page, data)
+}
+
+// chapterBody returns the content of chapter as XHTML, with the sources of
+// images replaced by what embed returns for them, or the images dropped
+// when it returns nothing.
+func chapterBody(chapter Chapter, embed func(src string) string) (string, error) {
+	context := &xhtml.Node{Type: xhtml.ElementNode, Data: "div", DataAtom: atom.Div}
+
+	nodes, err := xhtml.ParseFragment(strings.NewReader(CleanHTML(chapter.Content)), context)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	for _, node := range nodes {
+		writeXHTML(&body, node, embed)
+	}
+
+	return body.String(), nil
+}
+
+// writeXHTML writes node as well-formed XHTML.
+func writeXHTML(w *bytes.Buffer, node *xhtml.Node, embed func(src string) string) {
+	switch node.Type {
+	case xhtml.TextNode:
+		w.WriteString(html.EscapeString(node.Data))
+	case xhtml.ElementNode:
+		attributes := node.Attr
+
+		if node.Data == "img" {
+			attributes = make([]xhtml.Attribute, 0, len(node.Attr))
+			src, alt := "", ""
+			for _, attribute := range node.Attr {
+				switch attribute.Key {
+				case "src":
+					src = embed(attribute.Val)
+				case "alt":
+					alt = attribute.Val
+				case "srcset", "sizes", "loading":
+				default:
+					attributes = append(attributes, attribute)
+				}
+			}
+
+			if src == "" {
+				w.WriteString(html.EscapeString(alt))
+				return
+			}
+
+			attributes = append(attributes, xhtml.Attribute{Key: "src", Val: src}, xhtml.Attribute{Key: "alt", Val: alt})
+		}
+
+		w.WriteString("<" + node.Data)
+		for _, attribute := range attributes {
+			if attribute.Namespace != "" || strings.ContainsAny(attribute.Key, ":") {
+				continue
+			}
+			w.WriteString(" " + attribute.Key + `="` + html.EscapeString(attribute.Val) + `"`)
+		}
+
+		if voidElements[node.Data] {
+			w.WriteString("/>")
+			return
+		}
+
+		w.WriteString(">")
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			writeXHTML(w, child, embed)
+		}
+		w.WriteString("</" + node.Data + ">")
+	default:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			writeXHTML(w, child, embed)
+		}
+	}
+}
+
+var epubTemplates = template.Must(template.New("epub").Funcs(template.FuncMap{
+	"xml": html.EscapeString,
+	"stamp": func(date time.Time) string {
+		return date.UTC().Format("2006-01-02T15:04:05Z")
+	},
+	"day": func(date time.Time) string {
+		return date.UTC().Format("2 January 2006")
+	},
+}).Parse(`
+{{- define "container.xml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{end}}
+
+{{- define "content.opf" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{xml .Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Id}}</dc:identifier>
+    <dc:title>{{xml .Title}}</dc:title>
+    <dc:language>{{xml .Language}}</dc:language>
+    {{- if .Source}}
+    <dc:source>{{xml .Source}}</dc:source>
+    {{- end}}
+    <dc:date>{{stamp .Scraped}}</dc:date>
+    <meta property="dcterms:modified">{{stamp .Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="title" href="title.xhtml" media-type="application/xhtml+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- range $i, $file := .Files}}
+    <item id="chapter-{{$i}}" href="{{$file.Href}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+    {{- range $i, $image := .Images}}
+    <item id="image-{{$i}}" href="{{$image.Href}}" media-type="{{$image.Type}}"/>
+    {{- end}}
+  </manifest>
+  <spine>
+    <itemref idref="title"/>
+    <itemref idref="nav"/>
+    {{- range $i, $file := .Files}}
+    <itemref idref="chapter-{{$i}}"/>
+    {{- end}}
+  </spine>
+</package>
+{{end}}
+
+{{- define "nav.xhtml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{xml .Language}}">
+<head>
+  <title>Contents</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>Contents</h1>
+    <ol>
+      {{- range .Sections}}
+      {{- if .Title}}
+      <li><a href="{{(index .Files 0).Href}}">{{xml .Title}}</a>
+        <ol>
+          {{- range .Files}}
+          <li><a href="{{.Href}}">{{xml .Title}}</a></li>
+          {{- end}}
+        </ol>
+      </li>
+      {{- else}}
+      {{- range .Files}}
+      <li><a href="{{.Href}}">{{xml .Title}}</a></li>
+      {{- end}}
+      {{- end}}
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+{{end}}
+
+{{- define "title.xhtml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{xml .Language}}">
+<head>
+  <title>{{xml .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body class="title">
+  <h1>{{xml .Title}}</h1>
+  {{- if .Source}}
+  <p>Scraped from <a href="{{xml .Source}}">{{xml .Source}}</a></p>
+  {{- end}}
+  <p>on {{day .Scraped}}, {{len .Files}} pages</p>
+</body>
+</html>
+{{end}}
+
+{{- define "chapter.xhtml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{xml .Language}}">
+<head>
+  <title>{{xml .File.Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <h1>{{xml .File.Title}}</h1>
+  {{.File.Body}}
+</body>
+</html>
+{{end}}
+
+{{- define "style.css" -}}
+body { font-family: serif; line-height: 1.5; }
+h1 { font-size: 1.5em; }
+.title { text-align: center; margin-top: 30%; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.2em 0.4em; }
+{{end}}
+`))
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FeedFilter selects the entries of a feed. An entry matches when it
+// matches one of the values of every filter that has values.
+type FeedFilter struct {
+	// Categories match the categories and tags of entries, ignoring case.
+	Categories []string
+	// Tickers match symbols like BTC or $BTC mentioned in the title,
+	// summary, categories or content of entries.
+	Tickers []string
+	// Keywords match the title, summary or content of entries, ignoring
+	// case.
+	Keywords []string
+}
+
+// tickerWords matches the words of a text a ticker symbol is looked up
+// among, so that BTC matches $BTC and (BTC) but not BTCUSD.
+var tickerWords = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// Match reports whether entry passes the filter.
+func (filter FeedFilter) Match(entry Entry) bool {
+	if len(filter.Categories) > 0 {
+		found := false
+		for _, category := range entry.Categories() {
+			for _, wanted := range filter.Categories {
+				found = found || strings.EqualFold(category, wanted)
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	text := strings.Join([]string{entry.Title(), entry.Summary(), strings.Join(entry.Categories(), " "), entry.Content()}, "\n")
+
+	if len(filter.Tickers) > 0 {
+		words := map[string]bool{}
+		for _, word := range tickerWords.FindAllString(text, -1) {
+			words[word] = true
+		}
+
+		found := false
+		for _, ticker := range filter.Tickers {
+			found = found || words[strings.ToUpper(strings.TrimPrefix(ticker, "$"))]
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Keywords) > 0 {
+		found := false
+		for _, keyword := range filter.Keywords {
+			found = found || strings.Contains(strings.ToLower(text), strings.ToLower(keyword))
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Feed is a feed of scraped entries, newest first.
+type Feed struct {
+	// Title names the feed.
+	Title string
+	// Url is where the feed is published, if known.
+	Url string
+	// Link is the website of the feed, the root of Url when empty.
+	Link string
+	// Entries are the entries of the feed.
+	Entries []Entry
+}
+
+// maxFeedSummary is the longest summary of an entry without one, cut from
+// its content.
+const maxFeedSummary = 500
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	Guid        rssGuid  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+	Creators    []string `xml:"dc:creator"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// updated returns when the newest entry of the feed was published.
+func (feed Feed) updated() time.Time {
+	if len(feed.Entries) == 0 {
+		return time.Now().UTC()
+	}
+
+	return feed.Entries[0].Date()
+}
+
+// RSS returns the feed as RSS 2.0.
+func (feed Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:         feed.Title,
+		Link:          feed.Link,
+		Description:   "Documents scraped from " + feed.Title,
+		LastBuildDate: feed.updated().Format(time.RFC1123Z),
+		Items:         make([]rssItem, 0),
+	}
+
+	if feed.Url != "" {
+		channel.Self = &atomLink{Href: feed.Url, Rel: "self", Type: "application/rss+xml"}
+		if u, err := url.Parse(feed.Url); err == nil && channel.Link == "" {
+			channel.Link = u.Scheme + "://" + u.Host + "/"
+		}
+	}
+
+	if channel.Link == "" {
+		return nil, fmt.Errorf("RSS feed %s links to no website", feed.Title)
+	}
+
+	for _, entry := range feed.Entries {
+		item := rssItem{
+			Title:       entry.Title(),
+			Link:        entry.Link(),
+			Guid:        rssGuid{IsPermaLink: entry.Link() != "" && entry.Link() == entry.Id, Value: entryId(entry)},
+			PubDate:     entry.Date().Format(time.RFC1123Z),
+			Description: feedSummary(entry),
+			Categories:  entry.Categories(),
+			Creators:    entry.Authors(),
+		}
+
+		channel.Items = append(channel.Items, item)
+	}
+
+	return encodeFeed(rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Dc:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+// Atom returns the feed as Atom 1.0.
+func (feed Feed) Atom() ([]byte, error) {
+	document := atomFeed{
+		Id:      feed.Url,
+		Title:   feed.Title,
+		Updated: feed.updated().Format(time.RFC3339),
+		Author:  atomPerson{Name: "scrapers"},
+		Entries: make([]atomEntry, 0),
+	}
+
+	if document.Id == "" {
+		document.Id = "urn:scrapers:feed:" + Slug(feed.Title)
+	} else {
+		document.Links = append(document.Links, atomLink{Href: feed.Url, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, entry := range feed.Entries {
+		item := atomEntry{
+			Id:      entryId(entry),
+			Title:   entry.Title(),
+			Updated: entry.Date().Format(time.RFC3339),
+			Summary: feedSummary(entry),
+		}
+
+		if published, ok := entry.Published(); ok {
+			item.Published = published.Format(time.RFC3339)
+		}
+
+		if link := entry.Link(); link != "" {
+			item.Links = append(item.Links, atomLink{Href: link, Rel: "alternate"})
+		}
+
+		for _, author := range entry.Authors() {
+			item.Authors = append(item.Authors, atomPerson{Name: author})
+		}
+
+		for _, category := range entry.Categories() {
+			item.Categories = append(item.Categories, atomCategory{Term: category})
+		}
+
+		document.Entries = append(document.Entries, item)
+	}
+
+	return encodeFeed(document)
+}
+
+func encodeFeed(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// entryId returns a permanent id of entry, its link when it is a URL.
+func entryId(entry Entry) string {
+	if link := entry.Link(); link != "" {
+		return link
+	}
+
+	sum := sha1.Sum([]byte(entry.Site + "\n" + entry.Id + "\n" + entry.Path))
+
+	return "urn:sha1:" + hex.EncodeToString(sum[:])
+}
+
+// feedSummary returns the summary of entry, or the start of its content
+// without markup.
+func feedSummary(entry Entry) string {
+	if summary := entry.Summary(); summary != "" {
+		return summary
+	}
+
+	return Excerpt(entry.Content(), maxFeedSummary)
+}
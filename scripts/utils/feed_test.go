@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeedFilterTickers(t *testing.T) {
+	entry := Entry{Fields: map[string]interface{}{"title": "Why $BTC and (ETH) rallied", "content": "BTCUSD broke out"}}
+
+	for ticker, want := range map[string]bool{"BTC": true, "$eth": true, "USD": false, "BTCUSD": true, "SOL": false} {
+		if got := (FeedFilter{Tickers: []string{ticker}}).Match(entry); got != want {
+			t.Errorf("ticker %s matched %v, want %v", ticker, got, want)
+		}
+	}
+}
+
+func TestFeedRSSLink(t *testing.T) {
+	site, err := Feed{Title: "milkroad.com", Link: "https://milkroad.com/"}.RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(site), "<link>https://milkroad.com/</link>") {
+		t.Errorf("channel does not link to the site: %s", site)
+	}
+
+	all, err := Feed{Title: "All sites", Url: "https://example.com/feeds/all.rss"}.RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(all), "<link>https://example.com/</link>") {
+		t.Errorf("channel does not link to where it is published: %s", all)
+	}
+
+	if _, err := (Feed{Title: "All sites"}).RSS(); err == nil {
+		t.Error("expected an error for a channel without a link")
+	}
+}
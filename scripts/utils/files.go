@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// filesSink saves every document as JSON, CSV and Markdown files at the
+// path template of the site.
+type filesSink struct{}
+
+func init() {
+	registerSink("files", nil, func() (Sink, error) {
+		return filesSink{}, nil
+	})
+}
+
+// output is one of the files a document is saved as.
+type output struct {
+	path string
+	body []byte
+}
+
+// marker records that all files of a document were written, with the
+// SHA-256 of each, so that interrupted writes can be told apart. It also
+// tells the site and type of the document to tools reading the output.
+type marker struct {
+	Id    string            `json:"id"`
+	Site  string            `json:"site,omitempty"`
+	Type  string            `json:"type,omitempty"`
+	Saved time.Time         `json:"saved"`
+	Files map[string]string `json:"files"`
+}
+
+// render returns the JSON, CSV and Markdown files of record with the keys
+// of the site renamed.
+func render(record Record) ([]output, error) {
+	name, doc := record.Name, record.Doc
+
+	markdown, err := Markdown(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var table bytes.Buffer
+	csvWriter := csv.NewWriter(&table)
+	csvWriter.Write(doc.GetHeaders())
+	csvWriter.Write(doc.GetValues())
+	csvWriter.Flush()
+
+	return []output{
+		{path: documentPath(name, doc, "json"), body: record.Json},
+		{path: documentPath(name, doc, "csv"), body: []byte(changeKeys(site.Renames, table.String(), "%s"))},
+		{path: documentPath(name, doc, "md"), body: []byte(markdown)},
+	}, nil
+}
+
+// Write stages every output in a temporary file next to it and renames
+// them into place once all were written, then writes the completion marker.
+// Documents without a marker, or whose files no longer match it, were
+// interrupted by a crash and are written again.
+func (filesSink) Write(record Record) error {
+	outputs, err := render(record)
+	if err != nil {
+		return err
+	}
+	markerPath := documentPath(record.Name, record.Doc, "done")
+
+	if complete(markerPath) {
+		fmt.Printf("Found duplicate: %s\n", outputs[0].path)
+		return nil
+	}
+
+	if _, err := os.Stat(outputs[0].path); err == nil {
+		fmt.Printf("Redoing partial download: %s\n", outputs[0].path)
+	}
+
+	done := marker{Id: record.Id, Site: site.Name, Type: documentType(record.Doc), Saved: record.Saved, Files: map[string]string{}}
+
+	staged := make([]string, 0)
+	for _, out := range outputs {
+		temp, err := stage(out.path, out.body)
+		if err != nil {
+			unstage(staged)
+			return err
+		}
+
+		staged = append(staged, temp)
+
+		relative, err := filepath.Rel(filepath.Dir(markerPath), out.path)
+		if err != nil {
+			unstage(staged)
+			return err
+		}
+		done.Files[filepath.ToSlash(relative)] = checksum(out.body)
+	}
+
+	for i, out := range outputs {
+		if err := os.Rename(staged[i], out.path); err != nil {
+			unstage(staged[i:])
+			return err
+		}
+	}
+
+	file, err := json.MarshalIndent(done, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return replace(markerPath, file)
+}
+
+func (filesSink) Close() error {
+	return nil
+}
+
+// complete reports whether the marker at path exists and every file it
+// lists is still there with the content it was written with.
+func complete(path string) bool {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	done := marker{}
+	if err := json.Unmarshal(file, &done); err != nil || len(done.Files) == 0 {
+		return false
+	}
+
+	for relative, sum := range done.Files {
+		body, err := os.ReadFile(filepath.Join(filepath.Dir(path), filepath.FromSlash(relative)))
+		if err != nil || checksum(body) != sum {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stage writes body to a synced temporary file in the directory of path
+// and returns its name.
+func stage(path string, body []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = temp.Write(body)
+	if err == nil {
+		err = temp.Chmod(0644)
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+
+	return temp.Name(), nil
+}
+
+// replace atomically replaces the file at path with body.
+func replace(path string, body []byte) error {
+	temp, err := stage(path, body)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		unstage([]string{temp})
+		return err
+	}
+
+	return nil
+}
+
+func unstage(temps []string) {
+	for _, temp := range temps {
+		os.Remove(temp)
+	}
+}
+
+func checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFilesRedoInterruptedWrites(t *testing.T) {
+	options = Options{Output: t.TempDir()}
+	site = Site{Name: "example.com"}
+
+	doc := tableDoc{"https://example.com/1", "one"}
+	record := Record{Id: doc.link, Name: "one", Doc: doc, Json: []byte(`{"title": "one"}`), Saved: time.Now()}
+
+	jsonPath := documentPath(record.Name, doc, "json")
+	markerPath := documentPath(record.Name, doc, "done")
+
+	tests := []struct {
+		name      string
+		interrupt func() error
+	}{
+		{"missing marker", func() error {
+			return os.Remove(markerPath)
+		}},
+		{"bad marker", func() error {
+			return os.WriteFile(markerPath, []byte(`{"id": "https://example.com/1", "fil`), 0644)
+		}},
+		{"marker of other files", func() error {
+			return os.WriteFile(jsonPath, []byte(`{"ti`), 0644)
+		}},
+	}
+
+	for _, test := range tests {
+		if err := (filesSink{}).Write(record); err != nil {
+			t.Fatal(err)
+		}
+		if !complete(markerPath) {
+			t.Fatalf("%s: document is not complete after writing it", test.name)
+		}
+
+		if err := test.interrupt(); err != nil {
+			t.Fatal(err)
+		}
+		if complete(markerPath) {
+			t.Errorf("%s: interrupted write was taken as complete", test.name)
+		}
+
+		if err := (filesSink{}).Write(record); err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := os.ReadFile(jsonPath)
+		if err != nil || string(body) != string(record.Json) || !complete(markerPath) {
+			t.Errorf("%s: got %q (%v), want the document written again", test.name, body, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OutputEnv names the environment variable setting the default of --output.
+const OutputEnv = "SCRAPERS_OUTPUT"
+
+type Options struct {
+	// DryRun extracts and prints documents without writing anything.
+	DryRun bool
+	// Limit stops saving the documents of a source after this many, 0
+	// means no limit.
+	Limit int
+	// Output is the directory all sites are saved under.
+	Output string
+	// Path overrides the path template of the site.
+	Path string
+	// Sinks lists the sinks documents are saved to, separated by commas.
+	Sinks string
+}
+
+var options Options
+
+func parseFlags(args []string) {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+
+	flags.BoolVar(&options.DryRun, "dry-run", false, "extract and print documents without writing them")
+	flags.IntVar(&options.Limit, "limit", 0, "stop after `N` documents of every source")
+	sample := flags.Bool("sample", false, "shorthand for --dry-run --limit 5")
+	flags.StringVar(&options.Output, "output", DefaultOutput(), "save documents under `DIR`, $"+OutputEnv+" when set")
+	flags.StringVar(&options.Path, "path", "", "save documents at the path `TEMPLATE`, e.g. {site}/{yyyy}/{mm}/{slug}.{ext}")
+	flags.StringVar(&options.Sinks, "sink", "files", "save documents to the comma separated `SINKS`, of "+strings.Join(sinkNames(), ", "))
+
+	for _, name := range sinkNames() {
+		if sinkTypes[name].flags != nil {
+			sinkTypes[name].flags(flags)
+		}
+	}
+
+	flags.Parse(args)
+
+	if options.Path != "" {
+		if err := checkTemplate(options.Path); err != nil {
+			log.Fatalf("invalid --path: %v", err)
+		}
+	}
+
+	if *sample {
+		options.DryRun = true
+
+		if options.Limit == 0 {
+			options.Limit = 5
+		}
+	}
+}
+
+// DefaultOutput returns the directory documents are saved under when
+// --output is not given: $SCRAPERS_OUTPUT, or output at the root of the
+// project wherever the scripts are run from. Scripts built outside the
+// project and run outside of it save to output in the working directory.
+func DefaultOutput() string {
+	if output := os.Getenv(OutputEnv); output != "" {
+		return output
+	}
+
+	if root := projectRoot(); root != "" {
+		return filepath.Join(root, "output")
+	}
+
+	return "output"
+}
+
+// projectRoot returns the directory holding scripts/utils, looked up from
+// the source the scripts were built from, then from the working directory,
+// or "" when neither is in the project.
+func projectRoot() string {
+	starts := make([]string, 0)
+	if _, file, _, ok := runtime.Caller(0); ok && filepath.IsAbs(file) {
+		starts = append(starts, filepath.Dir(file))
+	}
+	if dir, err := os.Getwd(); err == nil {
+		starts = append(starts, dir)
+	}
+
+	for _, dir := range starts {
+		for {
+			if info, err := os.Stat(filepath.Join(dir, "scripts", "utils", "go.mod")); err == nil && !info.IsDir() {
+				return dir
+			}
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	return ""
+}
+
+// LimitReached reports whether count documents of a source are enough for
+// --limit, so that scripts collecting documents before saving them can stop
+// crawling the source.
+func LimitReached(count int) bool {
+	return options.Limit > 0 && count >= options.Limit
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultOutputIsAtProjectRoot(t *testing.T) {
+	t.Setenv(OutputEnv, "")
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	want := filepath.Join(dir, "..", "..", "output")
+
+	for _, from := range []string{dir, filepath.Join(dir, ".."), filepath.Join(dir, "..", ".."), t.TempDir()} {
+		if err := os.Chdir(from); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := DefaultOutput(); got != filepath.Clean(want) {
+			t.Errorf("run from %s: got %s, want %s", from, got, filepath.Clean(want))
+		}
+	}
+
+	t.Setenv(OutputEnv, "/tmp/documents")
+	if got := DefaultOutput(); got != "/tmp/documents" {
+		t.Errorf("got %s, want the directory of $%s", got, OutputEnv)
+	}
+}
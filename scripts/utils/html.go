@@ -0,0 +1,36 @@
+package utils
+
+import "github.com/microcosm-cc/bluemonday"
+
+// contentPolicy keeps the formatting of scraped content, such as headings,
+// lists, tables, code and images, and drops scripts, styles and handlers.
+var contentPolicy = bluemonday.UGCPolicy()
+
+// CleanHTML returns content as safe HTML to show in a page. Content
+// saved as Markdown, or scraped as plain text, is rendered first.
+func CleanHTML(content string) string {
+	if !isHTML(content) {
+		content = markdownHTML(content)
+	}
+
+	return contentPolicy.Sanitize(content)
+}
+
+// PlainText returns the text of the content, with runs of whitespace
+// collapsed to single spaces. HTML content is converted to Markdown first,
+// so the text is always that of the Markdown.
+func PlainText(content string) string {
+	return markdownText(HTMLMarkdown(content, ""))
+}
+
+// TextBlocks returns the paragraphs, headings, list items and other blocks
+// of the text of the content, as PlainText derives it.
+func TextBlocks(content string) []string {
+	return markdownBlocks(HTMLMarkdown(content, ""))
+}
+
+// Excerpt returns the start of the text of the content, cut to at most
+// limit characters.
+func Excerpt(content string, limit int) string {
+	return truncate(PlainText(content), limit)
+}
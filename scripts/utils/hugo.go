@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// featuredImage names the image of a bundle Hugo themes show as the image
+// of the page.
+const featuredImage = "featured"
+
+// Bundles writes documents as Hugo page bundles under content, one section
+// per site: content/<site>/<document>/index.md with the images of the
+// document next to it. Jekyll reads the same front matter.
+type Bundles struct {
+	// Dir is the root of the Hugo site.
+	Dir string
+	// Draft marks the pages as drafts.
+	Draft bool
+	// Fetch downloads the images of the documents into their bundles.
+	// Images link to where they were scraped from when it is nil or fails.
+	Fetch func(src string) ([]byte, error)
+}
+
+// WriteSection writes the _index.md of the section of site.
+func (bundles Bundles) WriteSection(site string) error {
+	front := &yaml.Node{Kind: yaml.MappingNode}
+	addFront(front, "title", site)
+	addFront(front, "params", map[string]string{"source": site})
+
+	return writeFrontMatter(filepath.Join(bundles.Dir, "content", Slug(site), "_index.md"), front, "")
+}
+
+// Write writes the bundle of entry.
+func (bundles Bundles) Write(entry Entry) error {
+	title := entry.Title()
+	if title == "" {
+		title = entry.Id
+	}
+
+	dir := filepath.Join(bundles.Dir, "content", Slug(entry.Site), Filename(title, entry.Id))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	images := map[string]string{}
+	download := func(src string, name string) string {
+		resolved, err := url.Parse(entry.Link())
+		if err == nil {
+			resolved, err = resolved.Parse(src)
+		}
+		if err != nil {
+			return src
+		}
+
+		if file, ok := images[resolved.String()]; ok {
+			return file
+		}
+		images[resolved.String()] = resolved.String()
+
+		if bundles.Fetch == nil {
+			return resolved.String()
+		}
+
+		body, err := bundles.Fetch(resolved.String())
+		if err != nil {
+			log.Printf("Unable to download image %s: %v", resolved, err)
+			return resolved.String()
+		}
+
+		_, ext := imageType(resolved.Path, body)
+		if ext == "" {
+			log.Printf("Unable to download image %s: unsupported type", resolved)
+			return resolved.String()
+		}
+
+		if name == "" {
+			sum := sha1.Sum([]byte(resolved.String()))
+			name = "image-" + hex.EncodeToString(sum[:4])
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name+ext), body, 0644); err != nil {
+			log.Printf("Unable to save image %s: %v", resolved, err)
+			return resolved.String()
+		}
+
+		images[resolved.String()] = name + ext
+
+		return name + ext
+	}
+
+	front := &yaml.Node{Kind: yaml.MappingNode}
+	addFront(front, "title", title)
+	addFront(front, "date", entry.Date().Format(time.RFC3339))
+	addFront(front, "lastmod", entry.Saved.UTC().Format(time.RFC3339))
+	addFront(front, "draft", bundles.Draft)
+	addFront(front, "summary", entry.Summary())
+	addFront(front, "categories", entry.List("category", "categories"))
+	addFront(front, "tags", entry.List("tags", "keywords"))
+
+	params := map[string]interface{}{"source": entry.Site, "type": entry.Type}
+	if link := entry.Link(); link != "" {
+		params["link"] = link
+	}
+	if authors := entry.Authors(); len(authors) > 0 {
+		params["authors"] = authors
+	}
+	if image := entry.Text("image", "thumbnail"); image != "" {
+		params["image"] = download(image, featuredImage)
+	}
+	addFront(front, "params", params)
+
+	body := replaceImages(HTMLMarkdown(entry.Content(), entry.Link()), func(src string) string {
+		return download(src, "")
+	})
+
+	return writeFrontMatter(filepath.Join(dir, "index.md"), front, "\n"+body+"\n")
+}
+
+// writeFrontMatter writes the file at path with the YAML front matter
+// followed by body.
+func writeFrontMatter(path string, front *yaml.Node, body string) error {
+	var out bytes.Buffer
+	out.WriteString("---\n")
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(front); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	out.WriteString("---\n")
+	out.WriteString(body)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHugoBundle(t *testing.T) {
+	dir := t.TempDir()
+
+	entry := Entry{
+		Site:  "example.com",
+		Type:  "article",
+		Id:    "https://example.com/posts/staking",
+		Saved: time.Date(2024, 1, 16, 8, 0, 0, 0, time.UTC),
+		Fields: map[string]interface{}{
+			"title":      "Staking: a guide",
+			"link":       "https://example.com/posts/staking",
+			"published":  "2024-01-15T10:00:00Z",
+			"categories": "DeFi; Staking",
+			"tags":       "eth, validators",
+			"image":      "/images/cover.png",
+			"content":    `<p>Stake <img src="chart.png" alt="chart"></p>`,
+		},
+		Separators: map[string]string{"categories": ";", "tags": ","},
+	}
+
+	fetched := make([]string, 0)
+	bundles := Bundles{Dir: dir, Fetch: func(src string) ([]byte, error) {
+		fetched = append(fetched, src)
+		return []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), nil
+	}}
+
+	if err := bundles.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := filepath.Join(dir, "content", "example-com", Filename("Staking: a guide", entry.Id))
+
+	index, err := os.ReadFile(filepath.Join(bundle, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.SplitN(string(index), "---\n", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		t.Fatalf("got %q, want front matter between --- lines", index)
+	}
+
+	var front struct {
+		Title      string
+		Date       string
+		Categories []string
+		Tags       []string
+		Params     map[string]interface{}
+	}
+	if err := yaml.Unmarshal([]byte(parts[1]), &front); err != nil {
+		t.Fatal(err)
+	}
+
+	if front.Title != "Staking: a guide" || !strings.HasPrefix(front.Date, "2024-01-15") {
+		t.Errorf("got title %q and date %q", front.Title, front.Date)
+	}
+	if strings.Join(front.Categories, "|") != "DeFi|Staking" || strings.Join(front.Tags, "|") != "eth|validators" {
+		t.Errorf("got categories %v and tags %v, want them split on the separators of the site", front.Categories, front.Tags)
+	}
+	if front.Params["image"] != featuredImage+".png" || front.Params["link"] != entry.Id {
+		t.Errorf("got params %v, want the featured image of the bundle", front.Params)
+	}
+
+	if len(fetched) != 2 || fetched[0] != "https://example.com/images/cover.png" || fetched[1] != "https://example.com/posts/chart.png" {
+		t.Errorf("fetched %v, want the images resolved against the link", fetched)
+	}
+
+	files, err := os.ReadDir(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("got %d files in the bundle, want index.md and both images", len(files))
+	}
+
+	for _, file := range files {
+		if name := file.Name(); strings.HasPrefix(name, "image-") && !strings.Contains(parts[2], "("+name+")") {
+			t.Errorf("body %q does not link to %s", parts[2], name)
+		}
+	}
+}
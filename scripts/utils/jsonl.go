@@ -0,0 +1,506 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// JsonlOptions configures the jsonl sink.
+type JsonlOptions struct {
+	// Rotate is "daily" to start a new file every day, or "none".
+	Rotate string
+	// Compress is "gzip", "zstd" or "none".
+	Compress string
+	// Mode is "append" to keep every line written, or "dedup" to compact
+	// the file to the last line of every document when the run finishes.
+	Mode string
+}
+
+var jsonlOptions JsonlOptions
+
+// indexEntry locates the line of a document in the uncompressed stream of
+// a JSON Lines file.
+type indexEntry struct {
+	Id     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// jsonlSink appends one document per line to <site>.jsonl and indexes the
+// byte offset of every line by document id in <site>.jsonl.idx.
+type jsonlSink struct {
+	path   string
+	file   *os.File
+	writer io.WriteCloser
+	index  *os.File
+	offset int64
+	// closed is set between closing a file and opening the next one, so
+	// that a sink whose next file failed to open is not closed twice.
+	closed bool
+}
+
+func init() {
+	registerSink("jsonl", func(flags *flag.FlagSet) {
+		flags.StringVar(&jsonlOptions.Rotate, "jsonl-rotate", "none", "start a new JSON Lines file `daily` or never (none)")
+		flags.StringVar(&jsonlOptions.Compress, "jsonl-compress", "none", "compress JSON Lines files with `gzip`, zstd or none")
+		flags.StringVar(&jsonlOptions.Mode, "jsonl-mode", "append", "`append` every document, or dedup to keep the last line of each")
+	}, openJsonl)
+}
+
+func jsonlPath(date time.Time) (string, error) {
+	name := site.Name
+
+	switch jsonlOptions.Rotate {
+	case "none":
+	case "daily":
+		name += "." + date.UTC().Format("2006-01-02")
+	default:
+		return "", fmt.Errorf("unknown rotation %q", jsonlOptions.Rotate)
+	}
+
+	name += ".jsonl"
+
+	switch jsonlOptions.Compress {
+	case "none":
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	default:
+		return "", fmt.Errorf("unknown compression %q", jsonlOptions.Compress)
+	}
+
+	return filepath.Join(options.Output, site.Name, name), nil
+}
+
+func openJsonl() (Sink, error) {
+	if jsonlOptions.Mode != "append" && jsonlOptions.Mode != "dedup" {
+		return nil, fmt.Errorf("unknown mode %q", jsonlOptions.Mode)
+	}
+
+	path, err := jsonlPath(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &jsonlSink{}
+	if err := sink.open(path); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (sink *jsonlSink) open(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	offset, err := recoverJsonl(path)
+	if err != nil {
+		return err
+	}
+
+	sink.path = path
+	sink.offset = offset
+
+	if sink.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return err
+	}
+
+	if sink.writer, err = compressor(sink.file); err != nil {
+		sink.file.Close()
+		return err
+	}
+
+	if sink.index, err = os.OpenFile(path+".idx", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		sink.file.Close()
+		return err
+	}
+
+	sink.closed = false
+
+	return nil
+}
+
+// recoverJsonl returns the length of the uncompressed stream of the file
+// at path as far as its index covers it. An uncompressed file is cut back
+// to that length, dropping a line a crashed run did not finish, a
+// compressed file is rewritten without its unfinished member or frame (see
+// recoverCompressed), and a file without an index is indexed again.
+func recoverJsonl(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		os.Remove(path + ".idx")
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := readIndex(path + ".idx")
+	if os.IsNotExist(err) {
+		return reindex(path)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	end := int64(0)
+	for _, entry := range entries {
+		if entry.Offset+entry.Length > end {
+			end = entry.Offset + entry.Length
+		}
+	}
+
+	if jsonlOptions.Compress != "none" {
+		return recoverCompressed(path, entries, end)
+	}
+
+	if info.Size() > end {
+		if err := os.Truncate(path, end); err != nil {
+			return 0, err
+		}
+	}
+
+	return end, nil
+}
+
+// recoverCompressed checks that the compressed file at path decompresses
+// to the end its index covers. A crashed run leaves its last gzip member or
+// zstd frame cut short, which makes the whole file unreadable, and may have
+// indexed lines still buffered in the compressor. The file is then
+// rewritten with the complete lines it decompresses to up to the end, and
+// its index with the entries of those lines.
+func recoverCompressed(path string, entries []indexEntry, end int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	stream := []byte{}
+	r, err := decompressor(file)
+	if err == nil {
+		stream, err = io.ReadAll(r)
+		r.Close()
+	}
+	file.Close()
+
+	if err == nil && int64(len(stream)) == end {
+		return end, nil
+	}
+
+	if int64(len(stream)) > end {
+		stream = stream[:end]
+	}
+	stream = stream[:bytes.LastIndexByte(stream, '\n')+1]
+
+	var compressed bytes.Buffer
+	writer, err := compressor(&compressed)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writer.Write(stream); err != nil {
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := replace(path, compressed.Bytes()); err != nil {
+		return 0, err
+	}
+
+	kept := make([]indexEntry, 0)
+	for _, entry := range entries {
+		if entry.Offset+entry.Length <= int64(len(stream)) {
+			kept = append(kept, entry)
+		}
+	}
+
+	return int64(len(stream)), writeIndex(path+".idx", kept)
+}
+
+// reindex rebuilds the index of the file at path from the id fields of
+// its lines.
+func reindex(path string) (int64, error) {
+	entries := make([]indexEntry, 0)
+	offset := int64(0)
+
+	err := readLines(path, func(line []byte) {
+		entries = append(entries, indexEntry{Id: lineId(line), Offset: offset, Length: int64(len(line))})
+		offset += int64(len(line))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, writeIndex(path+".idx", entries)
+}
+
+func (sink *jsonlSink) Write(record Record) error {
+	if path, _ := jsonlPath(record.Saved); path != sink.path || sink.closed {
+		if err := sink.Close(); err != nil {
+			return err
+		}
+
+		if err := sink.open(path); err != nil {
+			return err
+		}
+	}
+
+	var line bytes.Buffer
+	if err := json.Compact(&line, record.Json); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+
+	if _, err := sink.writer.Write(line.Bytes()); err != nil {
+		return err
+	}
+
+	id := record.Id
+	if id == "" {
+		id = record.Name
+	}
+
+	entry, err := json.Marshal(indexEntry{Id: id, Offset: sink.offset, Length: int64(line.Len())})
+	if err != nil {
+		return err
+	}
+
+	if _, err := sink.index.Write(append(entry, '\n')); err != nil {
+		return err
+	}
+
+	sink.offset += int64(line.Len())
+
+	return nil
+}
+
+func (sink *jsonlSink) Close() error {
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+
+	if err := sink.writer.Close(); err != nil {
+		return err
+	}
+
+	if sink.writer != io.WriteCloser(sink.file) {
+		if err := sink.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := sink.index.Close(); err != nil {
+		return err
+	}
+
+	if jsonlOptions.Mode == "dedup" {
+		return compactJsonl(sink.path)
+	}
+
+	return nil
+}
+
+// compactJsonl rewrites the file at path keeping only the last line of
+// every document, in the order the documents were first written.
+func compactJsonl(path string) error {
+	entries, err := readIndex(path + ".idx")
+	if err != nil {
+		return err
+	}
+
+	ids := map[int64]string{}
+	for _, entry := range entries {
+		ids[entry.Offset] = entry.Id
+	}
+
+	order := make([]string, 0)
+	lines := map[string][]byte{}
+	offset := int64(0)
+
+	err = readLines(path, func(line []byte) {
+		id, ok := ids[offset]
+		if !ok {
+			id = lineId(line)
+		}
+		offset += int64(len(line))
+
+		if _, seen := lines[id]; !seen {
+			order = append(order, id)
+		}
+		lines[id] = append([]byte{}, line...)
+	})
+	if err != nil {
+		return err
+	}
+
+	var stream bytes.Buffer
+	compacted := make([]indexEntry, 0)
+
+	for _, id := range order {
+		compacted = append(compacted, indexEntry{Id: id, Offset: int64(stream.Len()), Length: int64(len(lines[id]))})
+		stream.Write(lines[id])
+	}
+
+	var file bytes.Buffer
+	writer, err := compressor(&file)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(stream.Bytes()); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if err := replace(path, file.Bytes()); err != nil {
+		return err
+	}
+
+	return writeIndex(path+".idx", compacted)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// compressor wraps w in the compression of --jsonl-compress. Every run
+// appends a new gzip member or zstd frame, which readers of both formats
+// decompress as one stream.
+func compressor(w io.Writer) (io.WriteCloser, error) {
+	switch jsonlOptions.Compress {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	default:
+		if file, ok := w.(*os.File); ok {
+			return file, nil
+		}
+
+		return nopCloser{w}, nil
+	}
+}
+
+func decompressor(r io.Reader) (io.ReadCloser, error) {
+	switch jsonlOptions.Compress {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "zstd":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// readLines calls fn with every line of the uncompressed stream of the
+// file at path, including its newline.
+func readLines(path string, fn func(line []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := decompressor(file)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			fn(line)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readIndex(path string) ([]indexEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]indexEntry, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		entry := indexEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last entry of a crashed run may be cut short
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func writeIndex(path string, entries []indexEntry) error {
+	var index bytes.Buffer
+
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		index.Write(append(line, '\n'))
+	}
+
+	return replace(path, index.Bytes())
+}
+
+// lineId returns the canonical id of the document on line, read from its
+// id fields under their saved keys, or the line itself when it has none.
+func lineId(line []byte) string {
+	fields := map[string]interface{}{}
+	json.Unmarshal(line, &fields)
+
+	for _, key := range idKeys() {
+		if value, ok := fields[key].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	return string(bytes.TrimSpace(line))
+}
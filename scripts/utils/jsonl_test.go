@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJsonlRecoversCompressed(t *testing.T) {
+	for _, compress := range []string{"gzip", "zstd"} {
+		t.Run(compress, func(t *testing.T) {
+			site = Site{Name: "example.com"}
+			options.Output = t.TempDir()
+			jsonlOptions = JsonlOptions{Rotate: "none", Compress: compress, Mode: "append"}
+
+			sink, err := openJsonl()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				if err := sink.Write(jsonlRecord(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// a crashed run indexes lines the compressor still buffers and
+			// leaves its member or frame cut short
+			sink, err = openJsonl()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 3; i < 5; i++ {
+				if err := sink.Write(jsonlRecord(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			crashed := sink.(*jsonlSink)
+			crashed.file.Write([]byte{0x1f, 0x8b, 0x08, 0x28, 0xb5})
+			crashed.file.Close()
+			crashed.index.Close()
+
+			sink, err = openJsonl()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Write(jsonlRecord(5)); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			path, _ := jsonlPath(time.Now())
+
+			lines := 0
+			if err := readLines(path, func(line []byte) { lines++ }); err != nil {
+				t.Fatalf("file is unreadable after recovery: %v", err)
+			}
+
+			entries, err := readIndex(path + ".idx")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if lines != 4 || len(entries) != 4 {
+				t.Errorf("got %d lines and %d index entries, want the 3 lines of the first run and the line of the last", lines, len(entries))
+			}
+		})
+	}
+}
+
+func TestJsonlFailedRotationClosesOnce(t *testing.T) {
+	site = Site{Name: "example.com"}
+	options.Output = t.TempDir()
+	jsonlOptions = JsonlOptions{Rotate: "daily", Compress: "none", Mode: "dedup"}
+
+	sink, err := openJsonl()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file of the next day can not be created
+	next := time.Now().Add(24 * time.Hour)
+	path, _ := jsonlPath(next)
+	if err := os.Mkdir(path, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	record := jsonlRecord(0)
+	record.Saved = next
+	if err := sink.Write(record); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Errorf("closing the sink again: %v", err)
+	}
+}
+
+func jsonlRecord(i int) Record {
+	link := fmt.Sprintf("https://example.com/%d", i)
+
+	return Record{Id: link, Name: fmt.Sprint(i), Json: []byte(fmt.Sprintf(`{"link": %q}`, link)), Saved: time.Now()}
+}
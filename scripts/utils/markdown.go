@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// listFields are saved in front matter as lists of their items, split on
+// the separators the site declares (see Site.Separators).
+var listFields = map[string]bool{"category": true, "categories": true, "tags": true, "author": true, "authors": true}
+
+// timeFields are saved in front matter as RFC3339 dates when they parse.
+var timeFields = map[string]bool{"published": true, "date": true, "updated": true, "lastmod": true, "created_at": true}
+
+// Markdown returns the document of record as Markdown: its fields as YAML
+// front matter followed by the body field of the site, as it was scraped.
+func Markdown(record Record) (string, error) {
+	headers, values := record.Fields()
+	original := record.Doc.GetHeaders()
+
+	body := site.Body
+	if body == "" {
+		body = "content"
+	}
+
+	front := &yaml.Node{Kind: yaml.MappingNode}
+	text := ""
+
+	for i, header := range headers {
+		if i >= len(values) {
+			break
+		}
+
+		if original[i] == body {
+			text = values[i]
+			continue
+		}
+
+		front.Content = append(front.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: header}, frontValue(original[i], values[i]))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("---\n")
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(front); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	out.WriteString("---\n")
+	out.WriteString(text)
+
+	return out.String(), nil
+}
+
+func frontValue(field string, value string) *yaml.Node {
+	value = strings.TrimSpace(value)
+
+	if listFields[field] {
+		list := &yaml.Node{Kind: yaml.SequenceNode}
+
+		for _, item := range listItems(field, value) {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+
+		return list
+	}
+
+	if timeFields[field] {
+		if date, ok := parseDate(value); ok {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: date.Format("2006-01-02T15:04:05Z07:00")}
+		}
+	}
+
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// listItems returns the items of the value of field, split on the
+// separator the site declares for it.
+func listItems(field string, value string) []string {
+	return splitItems(value, site.Separators[field])
+}
+
+// splitItems returns the non-empty items of value separated by separator,
+// or value as the only item when separator is empty.
+func splitItems(value string, separator string) []string {
+	items := make([]string, 0)
+
+	parts := []string{value}
+	if separator != "" {
+		parts = strings.Split(value, separator)
+	}
+
+	for _, item := range parts {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type markdownDoc struct {
+	title   string
+	tags    string
+	content string
+}
+
+func (doc markdownDoc) GetHeaders() []string {
+	return []string{"title", "tags", "content"}
+}
+
+func (doc markdownDoc) GetValues() []string {
+	return []string{doc.title, doc.tags, doc.content}
+}
+
+func TestMarkdownFrontMatterRoundTrips(t *testing.T) {
+	site = Site{Name: "example.com", Separators: map[string]string{"tags": ","}}
+
+	titles := []string{
+		"Bitcoin: a primer",
+		"#1 coin # of the year",
+		`He said "stake" and 'unstake'`,
+		"first line\nsecond line: more",
+		"- not a list",
+		"2024",
+		"null",
+	}
+
+	for _, title := range titles {
+		doc := markdownDoc{title: title, tags: "a: b, #c", content: "The body\n"}
+
+		markdown, err := Markdown(Record{Id: "https://example.com/1", Name: "doc", Doc: doc})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts := strings.SplitN(markdown, "---\n", 3)
+		if len(parts) != 3 || parts[0] != "" {
+			t.Fatalf("got %q, want front matter between --- lines", markdown)
+		}
+
+		front := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(parts[1]), &front); err != nil {
+			t.Fatalf("front matter of %q does not parse: %v", title, err)
+		}
+
+		if front["title"] != title {
+			t.Errorf("got title %#v, want %q", front["title"], title)
+		}
+
+		tags, _ := front["tags"].([]interface{})
+		if len(tags) != 2 || tags[0] != "a: b" || tags[1] != "#c" {
+			t.Errorf("got tags %#v, want the items of the list", front["tags"])
+		}
+
+		if parts[2] != doc.content {
+			t.Errorf("got body %q, want the content", parts[2])
+		}
+	}
+}
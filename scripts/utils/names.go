@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSlugLength is the longest slug a filename is built from, in bytes,
+// leaving room for the hash, the extension and the limits of filesystems.
+const MaxSlugLength = 80
+
+// idFields are the fields a document is identified by, most canonical first.
+var idFields = []string{"link", "url", "guid", "id"}
+
+// transliterations spells out the lowercase letters that do not decompose
+// into a Latin letter and combining marks.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d", 'þ': "th",
+	'ı': "i", '&': "and", '₿': "btc",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// Filename returns the name a document titled title is saved under: a
+// transliterated, lowercase slug of the title followed by a short hash of
+// id, its canonical URL or GUID (see documentId). The same document gets the
+// same name on every run, and documents sharing a title still get different
+// names.
+func Filename(title string, id string) string {
+	if id == "" {
+		panic(fmt.Sprintf("document %q has no id to name it after", title))
+	}
+
+	sum := sha1.Sum([]byte(id))
+	hash := hex.EncodeToString(sum[:4])
+
+	slug := Slug(title)
+	if slug == "" {
+		return hash
+	}
+
+	return slug + "-" + hash
+}
+
+// Slug transliterates text to lowercase ASCII letters and digits separated
+// by single hyphens, cut at a word boundary to at most MaxSlugLength bytes.
+func Slug(text string) string {
+	var slug strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(text) {
+		spelled, ok := transliterations[r]
+		if !ok {
+			spelled = spellDecomposed(r)
+		}
+
+		if spelled == "" {
+			hyphen = hyphen || !ok
+			continue
+		}
+
+		if hyphen && slug.Len() > 0 {
+			slug.WriteByte('-')
+		}
+		hyphen = false
+
+		slug.WriteString(spelled)
+	}
+
+	result := slug.String()
+
+	if len(result) > MaxSlugLength {
+		result = result[:MaxSlugLength]
+
+		if i := strings.LastIndexByte(result, '-'); i > MaxSlugLength/2 {
+			result = result[:i]
+		}
+
+		result = strings.TrimSuffix(result, "-")
+	}
+
+	return result
+}
+
+// spellDecomposed spells r as the ASCII letters and digits it decomposes
+// into, transliterating the letters of other scripts, e.g. "o" for "ό".
+func spellDecomposed(r rune) string {
+	var spelled strings.Builder
+
+	for _, part := range norm.NFKD.String(string(r)) {
+		if transliterated, ok := transliterations[part]; ok {
+			spelled.WriteString(transliterated)
+		} else if part < unicode.MaxASCII && (unicode.IsLetter(part) || unicode.IsDigit(part)) {
+			spelled.WriteRune(part)
+		}
+	}
+
+	return spelled.String()
+}
+
+// unidentified are the checksums of the documents of the run without a
+// canonical id, by the id they were given.
+var unidentified = map[string][]string{}
+
+// documentId returns the canonical id of doc. Documents without one, e.g.
+// the terms of a glossary, are identified by the site and the title name
+// they are saved under, numbered when different documents of a run share
+// them, so that they do not replace each other.
+func documentId(doc Document, name string, file []byte) string {
+	if id := canonicalId(doc); id != "" {
+		return id
+	}
+
+	id := site.Name + "/" + name
+	sum := checksum(file)
+
+	sums := unidentified[id]
+	n := len(sums)
+	for i, seen := range sums {
+		if seen == sum {
+			n = i
+			break
+		}
+	}
+	if n == len(sums) {
+		unidentified[id] = append(sums, sum)
+	}
+
+	if n > 0 {
+		id = fmt.Sprintf("%s#%d", id, n+1)
+	}
+
+	return id
+}
+
+// canonicalId returns the most canonical identifying field of doc.
+func canonicalId(doc Document) string {
+	headers := doc.GetHeaders()
+	values := doc.GetValues()
+
+	for _, field := range idFields {
+		for i, header := range headers {
+			if header == field && i < len(values) && strings.TrimSpace(values[i]) != "" {
+				return strings.TrimSpace(values[i])
+			}
+		}
+	}
+
+	return ""
+}
+
+// documentType names the kind of doc: the type of the site, or the whole
+// name of its Go type in lowercase, e.g. pagedata for PageData.
+func documentType(doc Document) string {
+	if site.Type != "" {
+		return site.Type
+	}
+
+	t := reflect.TypeOf(doc)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.ToLower(t.Name())
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Bitcoin ETF Approved!", "bitcoin-etf-approved"},
+		{"  --Proof of Stake--  ", "proof-of-stake"},
+		{"Crème brûlée", "creme-brulee"},
+		{"Straße & Æther", "strasse-and-aether"},
+		{"Биткоин", "bitkoin"},
+		{"Κρυπτό", "krypto"},
+		{"₿ 100k", "btc-100k"},
+		{"比特币", ""},
+	}
+
+	for _, test := range tests {
+		if got := Slug(test.text); got != test.want {
+			t.Errorf("Slug(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSlugTruncation(t *testing.T) {
+	title := strings.Repeat("decentralized ", 10)
+
+	slug := Slug(title)
+	if len(slug) > MaxSlugLength {
+		t.Errorf("got a slug of %d bytes, want at most %d", len(slug), MaxSlugLength)
+	}
+	if strings.HasSuffix(slug, "-") || !strings.HasSuffix(slug, "decentralized") {
+		t.Errorf("got %q, want it cut at a word boundary", slug)
+	}
+
+	long := strings.Repeat("a", 200)
+	if got := Slug(long); got != long[:MaxSlugLength] {
+		t.Errorf("got %q, want a word without boundary cut at %d bytes", got, MaxSlugLength)
+	}
+}
+
+func TestDocumentsWithoutIdsKeepTheirNames(t *testing.T) {
+	site = Site{Name: "example.com"}
+	unidentified = map[string][]string{}
+
+	first := documentId(tableDoc{title: "Staking"}, "Staking", []byte(`{"title": "Staking", "content": "one"}`))
+	second := documentId(tableDoc{title: "Staking"}, "Staking", []byte(`{"title": "Staking", "content": "two"}`))
+	again := documentId(tableDoc{title: "Staking"}, "Staking", []byte(`{"title": "Staking", "content": "one"}`))
+
+	if first == "" || first == second {
+		t.Fatalf("got ids %q and %q, want different ids for different documents", first, second)
+	}
+	if again != first {
+		t.Errorf("got %q for the first document saved again, want %q", again, first)
+	}
+
+	if Filename("Staking", first) == Filename("Staking", second) {
+		t.Errorf("documents without ids sharing a title get the same filename %s", Filename("Staking", first))
+	}
+
+	// the first document keeps its name across runs
+	unidentified = map[string][]string{}
+	if id := documentId(tableDoc{title: "Staking"}, "Staking", []byte(`{"title": "Staking", "content": "changed"}`)); id != first {
+		t.Errorf("got %q on the next run, want %q", id, first)
+	}
+}
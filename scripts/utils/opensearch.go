@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenSearchEnv names the environment variable with the URL of the cluster
+// of the opensearch sink.
+const OpenSearchEnv = "SCRAPERS_OPENSEARCH_URL"
+
+// OpenSearchOptions configures the opensearch sink.
+type OpenSearchOptions struct {
+	// Url is the address of the cluster, with credentials if it needs them.
+	Url string
+	// Index prefixes the names of the indexes, followed by the document type.
+	Index string
+	// Batch is the number of documents sent by one bulk request.
+	Batch int
+	// Retries is how often a failed request or a rejected document is retried.
+	Retries int
+}
+
+var openSearchOptions OpenSearchOptions
+
+// openSearchBackoff is the wait before the first retry, doubled for every
+// retry after it.
+var openSearchBackoff = time.Second
+
+// openSearchSink indexes documents into Elasticsearch or OpenSearch through
+// the bulk API, in one index per document type, e.g. scrapers-article, under
+// an id derived from the canonical id so that reruns replace documents
+// instead of duplicating them.
+type openSearchSink struct {
+	url       string
+	client    *http.Client
+	templates map[string]bool
+	pending   []bulkItem
+	rejected  int
+}
+
+// bulkItem is a document waiting to be indexed.
+type bulkItem struct {
+	index     string
+	id        string
+	canonical string
+	source    []byte
+}
+
+// bulkResponse is the part of the bulk API response telling which
+// documents failed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Id     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func init() {
+	registerSink("opensearch", func(flags *flag.FlagSet) {
+		flags.StringVar(&openSearchOptions.Url, "opensearch-url", "", "index documents into the cluster at `URL` (default $"+OpenSearchEnv+" or http://localhost:9200)")
+		flags.StringVar(&openSearchOptions.Index, "opensearch-index", "scrapers", "prefix index names with `PREFIX`")
+		flags.IntVar(&openSearchOptions.Batch, "opensearch-batch", 500, "send `N` documents per bulk request")
+		flags.IntVar(&openSearchOptions.Retries, "opensearch-retries", 5, "retry failed requests and rejected documents `N` times")
+	}, openOpenSearch)
+}
+
+func openOpenSearch() (Sink, error) {
+	// the environment is read after parsing so that -h does not print the
+	// credentials of the URL
+	if openSearchOptions.Url == "" {
+		openSearchOptions.Url = os.Getenv(OpenSearchEnv)
+	}
+	if openSearchOptions.Url == "" {
+		openSearchOptions.Url = "http://localhost:9200"
+	}
+
+	if openSearchOptions.Batch <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, not %d", openSearchOptions.Batch)
+	}
+
+	if openSearchOptions.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative, not %d", openSearchOptions.Retries)
+	}
+
+	return &openSearchSink{
+		url:       strings.TrimSuffix(openSearchOptions.Url, "/"),
+		client:    &http.Client{Timeout: time.Minute},
+		templates: map[string]bool{},
+	}, nil
+}
+
+func (sink *openSearchSink) Write(record Record) error {
+	index := strings.ToLower(openSearchOptions.Index) + "-" + documentType(record.Doc)
+
+	if !sink.templates[index] {
+		if err := sink.putTemplate(index, record); err != nil {
+			return fmt.Errorf("unable to put template of %s: %v", index, err)
+		}
+		sink.templates[index] = true
+	}
+
+	source, err := openSearchSource(record)
+	if err != nil {
+		return err
+	}
+
+	canonical := newDocumentRow(record).id
+	sum := sha1.Sum([]byte(canonical))
+	sink.pending = append(sink.pending, bulkItem{index: index, id: hex.EncodeToString(sum[:]), canonical: canonical, source: source})
+
+	if len(sink.pending) >= openSearchOptions.Batch {
+		return sink.flush()
+	}
+
+	return nil
+}
+
+// putTemplate puts the index template of index, mapping the fields of the
+// type of record: ids and lists as keywords, titles, descriptions and
+// content as text, and every other string as text with a keyword subfield.
+// Fields of other types sharing the index are mapped by name the same way,
+// so the mappings do not depend on which site runs first.
+func (sink *openSearchSink) putTemplate(index string, record Record) error {
+	keyword := map[string]interface{}{"type": "keyword"}
+	text := map[string]interface{}{"type": "text"}
+	date := map[string]interface{}{"type": "date"}
+
+	properties := map[string]interface{}{
+		"canonical_id": keyword,
+		"site":         keyword,
+		"filename":     keyword,
+		"saved_at":     date,
+		"published_at": date,
+	}
+
+	headers, _ := record.Fields()
+	for i, field := range record.Doc.GetHeaders() {
+		switch {
+		case contains(idFields, field) || listFields[field]:
+			properties[headers[i]] = keyword
+		case field == "title" || field == "description" || field == "content":
+			properties[headers[i]] = text
+		}
+	}
+
+	keywords := append([]string{}, idFields...)
+	for field := range listFields {
+		keywords = append(keywords, field)
+	}
+	sort.Strings(keywords)
+
+	match := func(fields ...string) string {
+		return "^(" + strings.Join(fields, "|") + ")$"
+	}
+
+	template := map[string]interface{}{
+		"index_patterns": []string{index},
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"date_detection":    false,
+				"numeric_detection": false,
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"keywords": map[string]interface{}{
+							"match_mapping_type": "string",
+							"match_pattern":      "regex",
+							"match":              match(keywords...),
+							"mapping":            keyword,
+						},
+					},
+					map[string]interface{}{
+						"texts": map[string]interface{}{
+							"match_mapping_type": "string",
+							"match_pattern":      "regex",
+							"match":              match("title", "description", "content"),
+							"mapping":            text,
+						},
+					},
+					map[string]interface{}{
+						"strings": map[string]interface{}{
+							"match_mapping_type": "string",
+							"mapping": map[string]interface{}{
+								"type":   "text",
+								"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+							},
+						},
+					},
+				},
+				"properties": properties,
+			},
+		},
+	}
+
+	payload, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.send(http.MethodPut, "/_index_template/"+index, "application/json", payload)
+
+	return err
+}
+
+// openSearchSource returns the document of record as indexed: its fields,
+// with lists split into their items, and the canonical id, site, filename
+// and dates of the document.
+func openSearchSource(record Record) ([]byte, error) {
+	source := map[string]interface{}{}
+	if err := json.Unmarshal(record.Json, &source); err != nil {
+		return nil, err
+	}
+
+	headers, _ := record.Fields()
+	for i, field := range record.Doc.GetHeaders() {
+		if value, ok := source[headers[i]].(string); ok && listFields[field] {
+			source[headers[i]] = listItems(field, value)
+		}
+	}
+
+	source["canonical_id"] = record.Id
+	source["site"] = site.Name
+	source["filename"] = record.Name
+	source["saved_at"] = record.Saved.UTC().Format(time.RFC3339)
+	if at, ok := publishDate(record.Doc); ok {
+		source["published_at"] = at.UTC().Format(time.RFC3339)
+	}
+
+	return json.Marshal(source)
+}
+
+// flush sends the pending documents, retrying those rejected for a
+// temporary reason, and reports the others.
+func (sink *openSearchSink) flush() error {
+	items := sink.pending
+	sink.pending = nil
+
+	for attempt := 0; len(items) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > openSearchOptions.Retries {
+				sink.rejected += len(items)
+				fmt.Fprintf(os.Stderr, "unable to index %d documents after %d retries\n", len(items), openSearchOptions.Retries)
+				return nil
+			}
+
+			time.Sleep(openSearchBackoff << (attempt - 1))
+		}
+
+		retry, err := sink.bulk(items)
+		if err != nil {
+			return err
+		}
+
+		items = retry
+	}
+
+	return nil
+}
+
+// bulk sends items in one bulk request, returning those to retry.
+func (sink *openSearchSink) bulk(items []bulkItem) ([]bulkItem, error) {
+	var payload bytes.Buffer
+
+	for _, item := range items {
+		action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": item.index, "_id": item.id}})
+		if err != nil {
+			return nil, err
+		}
+
+		payload.Write(action)
+		payload.WriteByte('\n')
+		payload.Write(item.source)
+		payload.WriteByte('\n')
+	}
+
+	body, err := sink.send(http.MethodPost, "/_bulk", "application/x-ndjson", payload.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var response bulkResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unable to read bulk response: %v", err)
+	}
+
+	if !response.Errors {
+		return nil, nil
+	}
+
+	retry := make([]bulkItem, 0)
+
+	for i, result := range response.Items {
+		if i >= len(items) {
+			break
+		}
+
+		for _, outcome := range result {
+			switch {
+			case outcome.Status < 300:
+			case retryableStatus(outcome.Status):
+				retry = append(retry, items[i])
+			default:
+				sink.rejected++
+				fmt.Fprintf(os.Stderr, "unable to index %s: %s: %s\n", items[i].canonical, outcome.Error.Type, outcome.Error.Reason)
+			}
+		}
+	}
+
+	return retry, nil
+}
+
+// send makes a request to the cluster, retrying it when the cluster is
+// unreachable or overloaded, and returns the body of its response.
+func (sink *openSearchSink) send(method string, path string, contentType string, payload []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(openSearchBackoff << (attempt - 1))
+		}
+
+		request, err := http.NewRequest(method, sink.url+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", contentType)
+
+		response, err := sink.client.Do(request)
+		if err != nil {
+			if attempt < openSearchOptions.Retries {
+				continue
+			}
+			return nil, err
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if err == nil && response.StatusCode < 300 {
+			return body, nil
+		}
+
+		if err == nil {
+			err = fmt.Errorf("%s %s: %s: %s", method, path, response.Status, truncate(string(body), 200))
+		}
+
+		if !retryableStatus(response.StatusCode) || attempt >= openSearchOptions.Retries {
+			return nil, err
+		}
+	}
+}
+
+// retryableStatus tells whether a request or document failed with status
+// for a reason that may pass, like a full queue.
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Close sends the documents still pending and fails when any document was
+// rejected.
+func (sink *openSearchSink) Close() error {
+	if err := sink.flush(); err != nil {
+		return err
+	}
+
+	if sink.rejected > 0 {
+		return fmt.Errorf("%d documents were not indexed", sink.rejected)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standInCluster answers the requests of the opensearch sink, failing the
+// documents of statuses with that status on their first bulk request.
+type standInCluster struct {
+	mu        sync.Mutex
+	templates map[string][]byte
+	bulks     [][]byte
+	statuses  map[string]int
+	indexed   map[string]map[string]interface{}
+}
+
+func (cluster *standInCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cluster.mu.Lock()
+	defer cluster.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		cluster.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = body
+		w.Write([]byte(`{"acknowledged": true}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		cluster.bulks = append(cluster.bulks, body)
+		w.Write(cluster.bulk(body))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (cluster *standInCluster) bulk(body []byte) []byte {
+	items := make([]interface{}, 0)
+	errors := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		action := map[string]map[string]string{}
+		json.Unmarshal(scanner.Bytes(), &action)
+
+		scanner.Scan()
+		source := map[string]interface{}{}
+		json.Unmarshal(scanner.Bytes(), &source)
+
+		canonical, _ := source["canonical_id"].(string)
+		outcome := map[string]interface{}{"_id": action["index"]["_id"], "status": 201}
+
+		if status, ok := cluster.statuses[canonical]; ok {
+			delete(cluster.statuses, canonical)
+			errors = true
+			outcome["status"] = status
+			outcome["error"] = map[string]string{"type": "rejected", "reason": "stand-in"}
+		} else {
+			cluster.indexed[canonical] = source
+		}
+
+		items = append(items, map[string]interface{}{"index": outcome})
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{"errors": errors, "items": items})
+
+	return response
+}
+
+// openStandInCluster opens the opensearch sink on a stand-in cluster.
+func openStandInCluster(t *testing.T, batch int) (*openSearchSink, *standInCluster) {
+	t.Helper()
+
+	cluster := &standInCluster{templates: map[string][]byte{}, statuses: map[string]int{}, indexed: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(cluster)
+	t.Cleanup(server.Close)
+
+	site = Site{Name: "example.com"}
+	openSearchBackoff = 0
+	openSearchOptions = OpenSearchOptions{Url: server.URL, Index: "Scrapers", Batch: batch, Retries: 2}
+
+	sink, err := openOpenSearch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sink.(*openSearchSink), cluster
+}
+
+func writeOpenSearchDocs(t *testing.T, sink Sink, docs ...tableDoc) {
+	t.Helper()
+
+	for _, doc := range docs {
+		if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Json: []byte(`{"title": "` + doc.title + `"}`), Saved: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOpenSearchBulkFraming(t *testing.T) {
+	sink, cluster := openStandInCluster(t, 2)
+
+	writeOpenSearchDocs(t, sink, tableDoc{"https://example.com/1", "one"}, tableDoc{"https://example.com/2", "two"}, tableDoc{"https://example.com/3", "three"})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cluster.bulks) != 2 {
+		t.Fatalf("got %d bulk requests, want a full batch and the rest on close", len(cluster.bulks))
+	}
+
+	body := cluster.bulks[0]
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		t.Error("bulk body does not end with a newline")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want an action and a source line for each document", len(lines))
+	}
+
+	action := map[string]map[string]string{}
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatal(err)
+	}
+	if action["index"]["_index"] != "scrapers-tabledoc" || len(action["index"]["_id"]) != 40 {
+		t.Errorf("got action %v, want the index of the type and a hash of the canonical id", action)
+	}
+
+	source := cluster.indexed["https://example.com/1"]
+	if source["title"] != "one" || source["site"] != "example.com" || source["filename"] != "one" {
+		t.Errorf("got source %v", source)
+	}
+}
+
+func TestOpenSearchRetriesAndRejectsItems(t *testing.T) {
+	sink, cluster := openStandInCluster(t, 10)
+
+	cluster.statuses["https://example.com/1"] = http.StatusTooManyRequests
+	cluster.statuses["https://example.com/2"] = http.StatusBadRequest
+
+	writeOpenSearchDocs(t, sink, tableDoc{"https://example.com/1", "one"}, tableDoc{"https://example.com/2", "two"}, tableDoc{"https://example.com/3", "three"})
+
+	err := sink.Close()
+	if err == nil || !strings.Contains(err.Error(), "1 documents were not indexed") {
+		t.Errorf("got %v, want the rejected document reported", err)
+	}
+
+	if len(cluster.bulks) != 2 || strings.Count(string(cluster.bulks[1]), "\n") != 2 {
+		t.Errorf("got %d bulk requests, want the document of the full queue retried alone", len(cluster.bulks))
+	}
+
+	for _, id := range []string{"https://example.com/1", "https://example.com/3"} {
+		if _, ok := cluster.indexed[id]; !ok {
+			t.Errorf("%s was not indexed", id)
+		}
+	}
+}
+
+func TestOpenSearchTemplatePerType(t *testing.T) {
+	sink, cluster := openStandInCluster(t, 10)
+
+	writeOpenSearchDocs(t, sink, tableDoc{"https://example.com/1", "one"}, tableDoc{"https://example.com/2", "two"})
+	if err := sink.Write(Record{Id: "https://example.com/stats", Name: "stats", Doc: parquetDoc{Title: "stats", likes: "3"}, Json: []byte(`{"title": "stats"}`), Saved: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cluster.templates) != 2 {
+		t.Fatalf("got templates %v, want one for each type", cluster.templates)
+	}
+
+	template := struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Template      struct {
+			Mappings struct {
+				DynamicTemplates []map[string]struct {
+					Match   string                 `json:"match"`
+					Mapping map[string]interface{} `json:"mapping"`
+				} `json:"dynamic_templates"`
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"mappings"`
+		} `json:"template"`
+	}{}
+	if err := json.Unmarshal(cluster.templates["scrapers-tabledoc"], &template); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(template.IndexPatterns) != 1 || template.IndexPatterns[0] != "scrapers-tabledoc" {
+		t.Errorf("got index patterns %v, want the index of the type", template.IndexPatterns)
+	}
+
+	properties := template.Template.Mappings.Properties
+	if properties["link"]["type"] != "keyword" || properties["title"]["type"] != "text" || properties["saved_at"]["type"] != "date" {
+		t.Errorf("got properties %v, want the fields of the type mapped", properties)
+	}
+
+	keywords := template.Template.Mappings.DynamicTemplates[0]["keywords"]
+	if !strings.Contains(keywords.Match, "|link|") || !strings.Contains(keywords.Match, "|categories|") || keywords.Mapping["type"] != "keyword" {
+		t.Errorf("got keywords template %+v, want ids and lists of other types mapped as keywords", keywords)
+	}
+
+	if _, ok := cluster.templates["scrapers-parquetdoc"]; !ok {
+		t.Errorf("got templates %v, want one for the other type", cluster.templates)
+	}
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ParquetOptions configures the parquet sink.
+type ParquetOptions struct {
+	// Compression is "snappy", "zstd", "gzip" or "none".
+	Compression string
+	// RowGroupMb is the size of the row groups in megabytes.
+	RowGroupMb int64
+}
+
+var parquetOptions ParquetOptions
+
+// hiveDefaultPartition is the partition of documents without a publication
+// date, as Hive names it.
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// parquetSink writes a dataset partitioned Hive-style by site and publication
+// date to output/parquet/site=<site>/date=<yyyy-mm-dd>. Every run adds new
+// part files to the partitions, leaving those of earlier runs untouched.
+type parquetSink struct {
+	codec  parquet.CompressionCodec
+	run    string
+	parts  map[string]*parquetPart
+	fields []string
+	// typed is set for the fields whose columns are not strings
+	typed map[string]bool
+	// doc is the first document written, whose fields are the columns
+	doc Document
+}
+
+// parquetPart is a part file being written, hidden until it is complete.
+type parquetPart struct {
+	file   *os.File
+	path   string
+	writer *writer.CSVWriter
+}
+
+func init() {
+	registerSink("parquet", func(flags *flag.FlagSet) {
+		flags.StringVar(&parquetOptions.Compression, "parquet-compression", "snappy", "compress Parquet files with `snappy`, zstd, gzip or none")
+		flags.Int64Var(&parquetOptions.RowGroupMb, "parquet-row-group-mb", 128, "write Parquet row groups of `N` megabytes")
+	}, openParquet)
+}
+
+func openParquet() (Sink, error) {
+	codecs := map[string]parquet.CompressionCodec{
+		"snappy": parquet.CompressionCodec_SNAPPY,
+		"zstd":   parquet.CompressionCodec_ZSTD,
+		"gzip":   parquet.CompressionCodec_GZIP,
+		"none":   parquet.CompressionCodec_UNCOMPRESSED,
+	}
+
+	codec, ok := codecs[parquetOptions.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", parquetOptions.Compression)
+	}
+
+	if parquetOptions.RowGroupMb <= 0 {
+		return nil, fmt.Errorf("row group size must be positive, not %d", parquetOptions.RowGroupMb)
+	}
+
+	return &parquetSink{
+		codec: codec,
+		run:   fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405Z"), os.Getpid()),
+		parts: map[string]*parquetPart{},
+	}, nil
+}
+
+const utf8Column = "type=BYTE_ARRAY, convertedtype=UTF8"
+
+// parquetTypes maps the JSON types of the fields of documents to the types
+// of their columns. Fields of other types are written as UTF-8 strings, as
+// the documents format them.
+var parquetTypes = map[string]string{
+	"integer": "type=INT64",
+	"number":  "type=DOUBLE",
+	"boolean": "type=BOOLEAN",
+}
+
+// parquetSchema returns the schema of the rows of a site: the canonical id,
+// filename, save time and publication date of every document, followed by
+// the fields of doc, typed like the fields of its struct.
+func parquetSchema(doc Document, fields []string) []string {
+	schema := []string{
+		"name=canonical_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+		"name=filename, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+		"name=saved_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=REQUIRED",
+		"name=published_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL",
+	}
+
+	for _, field := range fields {
+		schema = append(schema, fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", field, parquetType(doc, field)))
+	}
+
+	return schema
+}
+
+// parquetType returns the type of the column of field of doc.
+func parquetType(doc Document, field string) string {
+	if property, ok := documentSchema(doc).Properties[field]; ok {
+		for _, t := range property.types() {
+			if parquetType, ok := parquetTypes[t]; ok {
+				return parquetType
+			}
+		}
+	}
+
+	return utf8Column
+}
+
+func (sink *parquetSink) Write(record Record) error {
+	headers, values := record.Fields()
+
+	if sink.fields == nil {
+		sink.fields = headers
+		sink.doc = record.Doc
+		sink.typed = map[string]bool{}
+		for _, field := range headers {
+			sink.typed[field] = parquetType(record.Doc, field) != utf8Column
+		}
+	}
+
+	date := hiveDefaultPartition
+	var published *string
+
+	if at, ok := publishDate(record.Doc); ok {
+		date = at.Format("2006-01-02")
+		published = stringPointer(strconv.FormatInt(at.UnixMilli(), 10))
+	}
+
+	part, err := sink.part(date)
+	if err != nil {
+		return err
+	}
+
+	row := []*string{
+		stringPointer(record.Id),
+		stringPointer(record.Name),
+		stringPointer(strconv.FormatInt(record.Saved.UnixMilli(), 10)),
+		published,
+	}
+
+	for _, field := range sink.fields {
+		var value *string
+		for i, header := range headers {
+			// empty values of typed columns are written as null
+			if header == field && i < len(values) && (values[i] != "" || !sink.typed[field]) {
+				value = stringPointer(values[i])
+			}
+		}
+
+		row = append(row, value)
+	}
+
+	return part.writer.WriteString(row)
+}
+
+// part returns the part file of the run in the partition of date.
+func (sink *parquetSink) part(date string) (*parquetPart, error) {
+	if part, ok := sink.parts[date]; ok {
+		return part, nil
+	}
+
+	dir := filepath.Join(options.Output, "parquet", "site="+partitionValue(site.Name), "date="+date)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "part-"+sink.run+".parquet")
+
+	file, err := os.CreateTemp(dir, ".part-*.parquet.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(parquetSchema(sink.doc, sink.fields), file, 1)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	pw.CompressionType = sink.codec
+	pw.RowGroupSize = parquetOptions.RowGroupMb * 1024 * 1024
+
+	part := &parquetPart{file: file, path: path, writer: pw}
+	sink.parts[date] = part
+
+	return part, nil
+}
+
+// Close finishes every part file and moves it into its partition.
+func (sink *parquetSink) Close() error {
+	var failed error
+
+	for _, part := range sink.parts {
+		err := part.writer.WriteStop()
+		if err == nil {
+			err = part.file.Sync()
+		}
+		if closeErr := part.file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(part.file.Name(), part.path)
+		}
+
+		if err != nil {
+			os.Remove(part.file.Name())
+			failed = err
+		}
+	}
+
+	return failed
+}
+
+// publishDate parses the publication date of doc, keeping the offset it
+// was published with.
+func publishDate(doc Document) (time.Time, bool) {
+	headers := doc.GetHeaders()
+	values := doc.GetValues()
+
+	for _, field := range dateFields {
+		for i, header := range headers {
+			if header == field && i < len(values) {
+				if date, ok := parseDate(values[i]); ok {
+					return date, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// partitionValue escapes the characters Hive does not allow in partition
+// directory names.
+func partitionValue(value string) string {
+	var escaped strings.Builder
+
+	for _, r := range value {
+		if strings.ContainsRune(`"#%'*/:=?\{[]^`, r) || r < ' ' {
+			fmt.Fprintf(&escaped, "%%%02X", r)
+			continue
+		}
+
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type parquetDoc struct {
+	Title     string `json:"title"`
+	Published string `json:"published"`
+	Likes     int    `json:"likes"`
+	likes     string
+}
+
+func (doc parquetDoc) GetHeaders() []string {
+	return []string{"title", "published", "likes"}
+}
+
+func (doc parquetDoc) GetValues() []string {
+	return []string{doc.Title, doc.Published, doc.likes}
+}
+
+func TestParquetSchemaTypesColumns(t *testing.T) {
+	site = Site{Name: "example.com"}
+
+	schema := parquetSchema(parquetDoc{}, []string{"title", "likes"})
+
+	want := []string{
+		"name=title, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL",
+		"name=likes, type=INT64, repetitiontype=OPTIONAL",
+	}
+	for i, column := range want {
+		if got := schema[4+i]; got != column {
+			t.Errorf("got column %q, want %q", got, column)
+		}
+	}
+}
+
+func TestParquetPartitionsByPublicationDate(t *testing.T) {
+	options.Output = t.TempDir()
+	site = Site{Name: "example.com"}
+	parquetOptions = ParquetOptions{Compression: "snappy", RowGroupMb: 1}
+
+	sink, err := openParquet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := []parquetDoc{
+		{Title: "late", Published: "Mon, 15 Jan 2024 23:30:00 -0500", Likes: 5, likes: "5"},
+		{Title: "undated"},
+	}
+	for _, doc := range docs {
+		if err := sink.Write(Record{Id: doc.Title, Name: doc.Title, Doc: doc, Saved: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, date := range []string{"2024-01-15", hiveDefaultPartition} {
+		parts, _ := filepath.Glob(filepath.Join(options.Output, "parquet", "site=example.com", "date="+date, "*.parquet"))
+		if len(parts) != 1 {
+			entries, _ := os.ReadDir(filepath.Join(options.Output, "parquet", "site=example.com"))
+			t.Errorf("got %d part files in date=%s, partitions %v", len(parts), date, entries)
+		}
+	}
+}
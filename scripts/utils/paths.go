@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Path templates for Site.Path. A template is relative to the output root
+// and may use {site}, {slug}, {ext} and the {yyyy}, {mm} and {dd} of the
+// document's publication date, e.g. "{site}/{yyyy}/{mm}/{slug}.{ext}".
+const (
+	FlatPath   = "{site}/{slug}.{ext}"
+	NestedPath = "{site}/{slug}/article.{ext}"
+)
+
+var dateFields = []string{"published", "date", "updated"}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"02/01/2006",
+}
+
+// checkTemplate reports why a path template can not be used: every document
+// needs the {slug} and every file of it the {ext} to land at a path of its
+// own, and the path must stay under the output root.
+func checkTemplate(template string) error {
+	for _, placeholder := range []string{"{slug}", "{ext}"} {
+		if !strings.Contains(template, placeholder) {
+			return fmt.Errorf("%q does not use %s", template, placeholder)
+		}
+	}
+
+	if strings.HasPrefix(template, "/") || filepath.IsAbs(template) {
+		return fmt.Errorf("%q is not relative to the output root", template)
+	}
+
+	for _, segment := range strings.FieldsFunc(template, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return fmt.Errorf("%q leaves the output root", template)
+		}
+	}
+
+	return nil
+}
+
+func pathTemplate() string {
+	if options.Path != "" {
+		return options.Path
+	}
+
+	if site.Path != "" {
+		return site.Path
+	}
+
+	return FlatPath
+}
+
+// documentPath returns where the ext file of the document saved as name
+// lands under the output root.
+func documentPath(name string, doc Document, ext string) string {
+	return filepath.Join(options.Output, filepath.FromSlash(expandTemplate(pathTemplate(), name, doc, ext)))
+}
+
+// expandTemplate fills in the placeholders of a path template for the ext
+// file of the document saved as name. Files that are not documents pass a
+// nil doc and are dated today, documents without a publication date are
+// dated 0000/00/00 so that they keep their path across runs.
+func expandTemplate(template string, name string, doc Document, ext string) string {
+	if strings.Contains(template, "{yyyy}") || strings.Contains(template, "{mm}") || strings.Contains(template, "{dd}") {
+		yyyy, mm, dd := "0000", "00", "00"
+
+		if date, ok := documentDate(doc); ok {
+			yyyy, mm, dd = date.Format("2006"), date.Format("01"), date.Format("02")
+		}
+
+		template = strings.NewReplacer(
+			"{yyyy}", yyyy,
+			"{mm}", mm,
+			"{dd}", dd,
+		).Replace(template)
+	}
+
+	return strings.NewReplacer(
+		"{site}", site.Name,
+		"{slug}", name,
+		"{ext}", ext,
+	).Replace(template)
+}
+
+// documentDate parses the publication date of doc, or returns the current
+// time for files that are not documents.
+func documentDate(doc Document) (time.Time, bool) {
+	if doc == nil {
+		return time.Now(), true
+	}
+
+	return publishDate(doc)
+}
+
+// parseDate parses a date in any of the formats the sites publish them in.
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresEnv names the environment variable with the connection string of
+// the postgres sink.
+const PostgresEnv = "SCRAPERS_POSTGRES_URL"
+
+// PostgresOptions configures the postgres sink.
+type PostgresOptions struct {
+	// Url is the connection string of the database.
+	Url string
+	// Batch is the number of documents upserted by one statement.
+	Batch int
+}
+
+var postgresOptions PostgresOptions
+
+// postgresColumns are the columns of a document in the documents table.
+var postgresColumns = []string{"id", "site", "name", "title", "link", "published_at", "fields", "first_seen", "last_seen"}
+
+// postgresMigrations bring the schema of the database up to date. Migration
+// i is recorded as version i+1 in schema_migrations once it is applied, and
+// is never changed after it is released.
+var postgresMigrations = []string{
+	`CREATE TABLE documents (
+		id text PRIMARY KEY,
+		site text NOT NULL,
+		name text NOT NULL,
+		title text,
+		link text,
+		published_at timestamptz,
+		fields jsonb NOT NULL,
+		first_seen timestamptz NOT NULL,
+		last_seen timestamptz NOT NULL
+	);
+	CREATE INDEX documents_site_published_at ON documents (site, published_at);
+	CREATE INDEX documents_fields ON documents USING gin (fields);`,
+}
+
+// postgresDriver is the database/sql driver the sink connects with.
+var postgresDriver = "postgres"
+
+// postgresLock is the advisory lock that keeps scripts started together
+// from migrating the schema at the same time.
+const postgresLock = 0x73637261706572
+
+// postgresSink upserts the documents of every site by canonical id into a
+// documents table, their fields in a JSONB column, a batch at a time.
+type postgresSink struct {
+	db      *sql.DB
+	pending [][]interface{}
+	ids     map[string]int
+}
+
+func init() {
+	registerSink("postgres", func(flags *flag.FlagSet) {
+		flags.StringVar(&postgresOptions.Url, "postgres-url", "", "save documents to the PostgreSQL database at `URL` (default $"+PostgresEnv+")")
+		flags.IntVar(&postgresOptions.Batch, "postgres-batch", 100, "upsert `N` documents per statement")
+	}, openPostgres)
+}
+
+func openPostgres() (Sink, error) {
+	// the environment is read after parsing so that -h does not print the
+	// password of the connection string
+	if postgresOptions.Url == "" {
+		postgresOptions.Url = os.Getenv(PostgresEnv)
+	}
+
+	if postgresOptions.Url == "" {
+		return nil, fmt.Errorf("no database, set --postgres-url or %s", PostgresEnv)
+	}
+
+	if postgresOptions.Batch <= 0 || postgresOptions.Batch*len(postgresColumns) > 65535 {
+		return nil, fmt.Errorf("batch size must be between 1 and %d, not %d", 65535/len(postgresColumns), postgresOptions.Batch)
+	}
+
+	db, err := sql.Open(postgresDriver, postgresOptions.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &postgresSink{db: db, ids: map[string]int{}}, nil
+}
+
+// migratePostgres applies the migrations the database is missing in one
+// transaction.
+func migratePostgres(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", postgresLock); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	version := 0
+	if err := tx.QueryRow("SELECT coalesce(max(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return err
+	}
+
+	if version > len(postgresMigrations) {
+		return fmt.Errorf("database schema version %d is newer than %d", version, len(postgresMigrations))
+	}
+
+	for ; version < len(postgresMigrations); version++ {
+		if _, err := tx.Exec(postgresMigrations[version]); err != nil {
+			return fmt.Errorf("unable to migrate to version %d: %v", version+1, err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version+1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (sink *postgresSink) Write(record Record) error {
+	row := newDocumentRow(record)
+
+	var published interface{}
+	if at, ok := publishDate(record.Doc); ok {
+		published = at
+	}
+
+	saved := record.Saved.UTC()
+	values := []interface{}{row.id, site.Name, record.Name, row.title, row.link, published, string(record.Json), saved, saved}
+
+	// a statement cannot upsert the same row twice
+	if i, ok := sink.ids[row.id]; ok {
+		sink.pending[i] = values
+		return nil
+	}
+
+	sink.ids[row.id] = len(sink.pending)
+	sink.pending = append(sink.pending, values)
+
+	if len(sink.pending) >= postgresOptions.Batch {
+		return sink.flush()
+	}
+
+	return nil
+}
+
+// flush upserts the pending documents, keeping the first_seen of those
+// already in the table.
+func (sink *postgresSink) flush() error {
+	if len(sink.pending) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	args := make([]interface{}, 0)
+
+	query.WriteString("INSERT INTO documents (" + strings.Join(postgresColumns, ", ") + ") VALUES ")
+
+	for i, values := range sink.pending {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		placeholders := make([]string, 0)
+		for _, value := range values {
+			args = append(args, value)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+
+		query.WriteString("(" + strings.Join(placeholders, ", ") + ")")
+	}
+
+	updates := make([]string, 0)
+	for _, column := range postgresColumns {
+		if column != "id" && column != "first_seen" {
+			updates = append(updates, column+" = excluded."+column)
+		}
+	}
+
+	query.WriteString(" ON CONFLICT (id) DO UPDATE SET " + strings.Join(updates, ", "))
+
+	if _, err := sink.db.Exec(query.String(), args...); err != nil {
+		return fmt.Errorf("unable to upsert %d documents: %v", len(sink.pending), err)
+	}
+
+	sink.pending = nil
+	sink.ids = map[string]int{}
+
+	return nil
+}
+
+// Close upserts the documents still pending.
+func (sink *postgresSink) Close() error {
+	err := sink.flush()
+
+	if closeErr := sink.db.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
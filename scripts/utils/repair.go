@@ -0,0 +1,466 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Suggestion is a candidate replacement for a selector that stopped matching.
+type Suggestion struct {
+	Selector string
+	Score    float64
+	Preview  string
+}
+
+type signature struct {
+	tag     string
+	id      string
+	classes []string
+	path    []string
+}
+
+var ignoredTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "nav": true, "header": true, "footer": true,
+	"aside": true, "form": true, "button": true, "svg": true, "iframe": true, "select": true,
+}
+
+var paragraphTags = map[string]bool{"p": true, "pre": true, "blockquote": true, "li": true, "td": true}
+
+var titleTags = map[string]float64{"h1": 1, "h2": 0.7, "h3": 0.5}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+var moduleClass = regexp.MustCompile(`^(.+?__)[a-zA-Z0-9_-]{4,8}$`)
+
+var camelWord = regexp.MustCompile(`^[a-z]+|[A-Z][a-z]+`)
+
+var vowels = regexp.MustCompile(`[aeiouyAEIOUY]`)
+
+// SuggestSelectors scores the nodes of page as replacements for the
+// selector of field. Title fields are scored as headings, all other fields
+// as text blocks by paragraph text, text density, link density and position.
+// When a known-good page is given, candidates resembling the node the old
+// selector matched there are ranked higher.
+func SuggestSelectors(page []byte, field string, known *KnownGood) ([]Suggestion, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := doc.Find("body *").Nodes
+	position := map[*html.Node]float64{}
+	for i, node := range nodes {
+		position[node] = float64(i) / float64(len(nodes)+1)
+	}
+
+	var scores map[*html.Node]float64
+	if field == "title" {
+		scores = scoreTitles(doc, position)
+	} else {
+		scores = scoreBlocks(doc, position)
+	}
+
+	normalize(scores)
+
+	if reference := knownSignature(known); reference != nil {
+		for node := range scores {
+			scores[node] = 0.6*scores[node] + 0.4*reference.similarity(signatureOf(node))
+		}
+	}
+
+	candidates := make([]*html.Node, 0)
+	for node := range scores {
+		candidates = append(candidates, node)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+
+	suggestions := make([]Suggestion, 0)
+	seen := map[string]bool{}
+
+	for _, node := range candidates {
+		if len(suggestions) == 5 {
+			break
+		}
+
+		selector := selectorFor(doc, node)
+		if selector == "" || seen[selector] {
+			continue
+		}
+		seen[selector] = true
+
+		preview := textOf(goquery.NewDocumentFromNode(node).Selection)
+		if runes := []rune(preview); len(runes) > 80 {
+			preview = string(runes[:80]) + "..."
+		}
+
+		suggestions = append(suggestions, Suggestion{Selector: selector, Score: scores[node], Preview: preview})
+	}
+
+	return suggestions, nil
+}
+
+func textOf(s *goquery.Selection) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s.Text(), " "))
+}
+
+func ignored(s *goquery.Selection) bool {
+	for _, node := range append(s.Nodes, s.Parents().Nodes...) {
+		if ignoredTags[node.Data] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scoreBlocks(doc *goquery.Document, position map[*html.Node]float64) map[*html.Node]float64 {
+	scores := map[*html.Node]float64{}
+
+	doc.Find("body *").Each(func(i int, s *goquery.Selection) {
+		node := s.Nodes[0]
+		if !paragraphTags[node.Data] || ignored(s) {
+			return
+		}
+
+		text := textOf(s)
+		if len(text) < 25 {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+
+		if parent := node.Parent; parent != nil && parent.Type == html.ElementNode && parent.Data != "body" {
+			scores[parent] += score
+
+			if grandparent := parent.Parent; grandparent != nil && grandparent.Type == html.ElementNode && grandparent.Data != "body" {
+				scores[grandparent] += score / 2
+			}
+		}
+	})
+
+	for node := range scores {
+		s := goquery.NewDocumentFromNode(node).Selection
+
+		text := float64(len(textOf(s)))
+		if text == 0 {
+			delete(scores, node)
+			continue
+		}
+
+		links := 0.0
+		s.Find("a").Each(func(i int, a *goquery.Selection) {
+			links += float64(len(textOf(a)))
+		})
+
+		tags := float64(s.Find("*").Length() + 1)
+		density := math.Min(1, text/tags/40)
+
+		scores[node] *= (1 - links/text) * (0.5 + 0.5*density) * (1 - 0.25*position[node])
+	}
+
+	return scores
+}
+
+func scoreTitles(doc *goquery.Document, position map[*html.Node]float64) map[*html.Node]float64 {
+	scores := map[*html.Node]float64{}
+
+	pageTitle := words(textOf(doc.Find("title")))
+	if og, ok := doc.Find(`meta[property="og:title"]`).Attr("content"); ok {
+		pageTitle = words(og)
+	}
+
+	doc.Find(`h1, h2, h3, [class*="title"], [class*="headline"], [itemprop="headline"]`).Each(func(i int, s *goquery.Selection) {
+		node := s.Nodes[0]
+		if ignored(s) {
+			return
+		}
+
+		text := textOf(s)
+		if text == "" {
+			return
+		}
+
+		weight, ok := titleTags[node.Data]
+		if !ok {
+			weight = 0.4
+		}
+
+		if len(text) < 10 || len(text) > 200 {
+			weight *= 0.3
+		}
+
+		scores[node] = weight*(1-0.5*position[node]) + 0.5*overlap(pageTitle, words(text))
+	})
+
+	return scores
+}
+
+func words(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		set[word] = true
+	}
+
+	return set
+}
+
+func overlap(a map[string]bool, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func normalize(scores map[*html.Node]float64) {
+	max := 0.0
+	for _, score := range scores {
+		max = math.Max(max, score)
+	}
+
+	if max == 0 {
+		return
+	}
+
+	for node := range scores {
+		scores[node] /= max
+	}
+}
+
+func knownSignature(known *KnownGood) *signature {
+	if known == nil {
+		return nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(known.Html))
+	if err != nil {
+		return nil
+	}
+
+	matched := doc.Find(known.Selector)
+	if matched.Length() == 0 {
+		return nil
+	}
+
+	sig := signatureOf(matched.Nodes[0])
+
+	return &sig
+}
+
+func signatureOf(node *html.Node) signature {
+	sig := signature{tag: node.Data}
+
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "id":
+			sig.id = attr.Val
+		case "class":
+			sig.classes = strings.Fields(attr.Val)
+		}
+	}
+
+	for parent := node.Parent; parent != nil && parent.Type == html.ElementNode; parent = parent.Parent {
+		sig.path = append(sig.path, parent.Data)
+	}
+
+	return sig
+}
+
+// hashed reports whether a class name or id was generated by a CSS-in-JS
+// library (styled-components, emotion, react-native-web), which changes on
+// every deploy and makes a poor selector.
+func hashed(name string) bool {
+	if strings.HasPrefix(name, "sc-") || strings.HasPrefix(name, "css-") {
+		return true
+	}
+
+	if len(name) >= 5 && len(name) <= 8 && strings.ToLower(name) != name && strings.ToUpper(name) != name && strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) == -1 && !camelCase(name) {
+		return true
+	}
+
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' }) {
+		if len(part) >= 4 && strings.IndexFunc(part, unicode.IsDigit) != -1 && strings.IndexFunc(part, unicode.IsLetter) != -1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// camelCase reports whether name reads as camelCase words, e.g. "navBar",
+// unlike the generated "jzoMnb" or "bjHQTa".
+func camelCase(name string) bool {
+	words := camelWord.FindAllString(name, -1)
+	if strings.Join(words, "") != name {
+		return false
+	}
+
+	for _, word := range words {
+		if !vowels.MatchString(word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// moduleStem returns the part of a CSS module class name before its
+// generated suffix, so that "markdown_markdownBody__i1xqq" and
+// "markdown_markdownBody__x9f2a" share the stem "markdown_markdownBody__".
+func moduleStem(class string) (string, bool) {
+	match := moduleClass.FindStringSubmatch(class)
+	if match == nil || !hashed(strings.TrimPrefix(class, match[1])) {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+func stem(class string) string {
+	if stem, ok := moduleStem(class); ok {
+		return stem
+	}
+
+	return class
+}
+
+func (sig signature) similarity(other signature) float64 {
+	score := 0.0
+
+	if sig.tag == other.tag {
+		score += 0.3
+	}
+
+	if sig.id != "" && sig.id == other.id {
+		score += 0.2
+	}
+
+	if len(sig.classes) > 0 && len(other.classes) > 0 {
+		a := map[string]bool{}
+		b := map[string]bool{}
+		for _, class := range sig.classes {
+			a[stem(class)] = true
+		}
+		for _, class := range other.classes {
+			b[stem(class)] = true
+		}
+
+		score += 0.3 * overlap(a, b)
+	}
+
+	depth := len(sig.path) - len(other.path)
+	if depth >= -1 && depth <= 1 {
+		score += 0.1
+	}
+
+	shared := 0
+	for i := 0; i < len(sig.path) && i < len(other.path) && sig.path[i] == other.path[i]; i++ {
+		shared++
+	}
+	if len(sig.path) > 0 {
+		score += 0.1 * float64(shared) / float64(len(sig.path))
+	}
+
+	return score
+}
+
+// selectorFor builds the shortest selector that uniquely matches node,
+// preferring ids and stable class names over positional paths.
+func selectorFor(doc *goquery.Document, node *html.Node) string {
+	for _, selector := range localSelectors(node) {
+		if matchesOnly(doc, selector, node) {
+			return selector
+		}
+	}
+
+	path := make([]string, 0)
+	for current := node; current != nil && current.Type == html.ElementNode && len(path) < 5; current = current.Parent {
+		part := current.Data
+		if current.Data != "body" && current.Data != "html" {
+			part = fmt.Sprintf("%s:nth-of-type(%d)", current.Data, indexOfType(current))
+		}
+
+		anchored := false
+		for _, selector := range localSelectors(current) {
+			if current != node && doc.Find(selector).Length() == 1 {
+				part = selector
+				anchored = true
+				break
+			}
+		}
+
+		path = append([]string{part}, path...)
+
+		selector := strings.Join(path, " > ")
+		if matchesOnly(doc, selector, node) {
+			return selector
+		}
+
+		if anchored {
+			break
+		}
+	}
+
+	return ""
+}
+
+func localSelectors(node *html.Node) []string {
+	selectors := make([]string, 0)
+	sig := signatureOf(node)
+
+	if sig.id != "" && !hashed(sig.id) {
+		selectors = append(selectors, "#"+sig.id)
+	}
+
+	for _, class := range sig.classes {
+		if stem, ok := moduleStem(class); ok {
+			selectors = append(selectors, fmt.Sprintf(`%s[class*="%s"]`, sig.tag, stem))
+		} else if !hashed(class) {
+			selectors = append(selectors, "."+class, sig.tag+"."+class)
+		}
+	}
+
+	return append(selectors, sig.tag)
+}
+
+func indexOfType(node *html.Node) int {
+	index := 1
+	for sibling := node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode && sibling.Data == node.Data {
+			index++
+		}
+	}
+
+	return index
+}
+
+func matchesOnly(doc *goquery.Document, selector string, node *html.Node) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	matched := doc.Find(selector)
+
+	return matched.Length() == 1 && matched.Nodes[0] == node
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const repairPage = `<html><head><title>Bitcoin ETF approved by the SEC | News</title></head><body>
+<header><nav><a href="/">Home</a><a href="/news">News</a></nav></header>
+<div class="layout">
+  <aside class="sidebar"><ul><li><a href="/a">A very long related article link number one</a></li><li><a href="/b">Another very long related article link number two</a></li></ul></aside>
+  <main>
+    <h1 class="headline">Bitcoin ETF approved by the SEC</h1>
+    <div class="article-body">
+      <p>The Securities and Exchange Commission approved the first spot bitcoin exchange-traded funds on Wednesday, after a decade of rejections.</p>
+      <p>Issuers including BlackRock, Fidelity and Grayscale can start trading their funds as soon as Thursday, analysts said.</p>
+      <p>The decision follows a court ruling last year, which found that the regulator had failed to explain its earlier denials.</p>
+    </div>
+  </main>
+</div>
+<footer><p>Copyright, all rights reserved, and some other words in the footer.</p></footer>
+</body></html>`
+
+func TestSuggestSelectors(t *testing.T) {
+	cases := []struct {
+		field    string
+		selector string
+	}{
+		{"content", ".article-body"},
+		{"title", ".headline"},
+	}
+
+	for _, c := range cases {
+		suggestions, err := SuggestSelectors([]byte(repairPage), c.field, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(suggestions) == 0 || suggestions[0].Selector != c.selector {
+			t.Errorf("%s: got %+v, want %q first", c.field, suggestions, c.selector)
+		}
+	}
+}
+
+func TestSuggestSelectorsKnownGood(t *testing.T) {
+	page := `<html><body>
+<div class="markdown_markdownBody__x9f2a"><p>Ethereum is a decentralized blockchain with smart contract functionality, and ether is its native cryptocurrency.</p></div>
+<div class="comments"><p>Bitcoin is a decentralized digital currency without a central bank, and bitcoin is its native cryptocurrency.</p></div>
+</body></html>`
+	known := &KnownGood{
+		Selector: ".markdown_markdownBody__i1xqq",
+		Html:     `<html><body><div class="markdown_markdownBody__i1xqq"><p>Old text</p></div></body></html>`,
+	}
+
+	suggestions, err := SuggestSelectors([]byte(page), "content", known)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `div[class*="markdown_markdownBody__"]`
+	if len(suggestions) == 0 || suggestions[0].Selector != want {
+		t.Errorf("got %+v, want %q first", suggestions, want)
+	}
+}
+
+func TestSuggestSelectorsPreview(t *testing.T) {
+	page := `<html><body><div id="content"><p>` + strings.Repeat("Биткоин вырос, ", 20) + `</p></div></body></html>`
+
+	suggestions, err := SuggestSelectors([]byte(page), "content", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suggestion := range suggestions {
+		if !utf8.ValidString(suggestion.Preview) {
+			t.Errorf("preview %q is not valid UTF-8", suggestion.Preview)
+		}
+	}
+}
+
+func TestHashed(t *testing.T) {
+	cases := []struct {
+		name   string
+		hashed bool
+	}{
+		{"jzoMnb", true},
+		{"bjHQTa", true},
+		{"dCEoLC", true},
+		{"sc-bztbEt", true},
+		{"css-1qaijid", true},
+		{"r-1pa6394", true},
+		{"navBar", false},
+		{"sideMenu", false},
+		{"article-body", false},
+		{"headline", false},
+		{"post-content", false},
+	}
+
+	for _, c := range cases {
+		if got := hashed(c.name); got != c.hashed {
+			t.Errorf("hashed(%q) = %v, want %v", c.name, got, c.hashed)
+		}
+	}
+}
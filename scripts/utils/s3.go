@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Env names the environment variable with the endpoint of the s3 sink.
+// The credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+const S3Env = "SCRAPERS_S3_ENDPOINT"
+
+// S3Options configures the s3 sink.
+type S3Options struct {
+	// Endpoint is the URL of the storage, e.g. http://localhost:9000 for
+	// MinIO or https://<account>.r2.cloudflarestorage.com for R2.
+	Endpoint string
+	// Region is the region of the buckets, for the providers needing it.
+	Region string
+	// Bucket is the path template of the bucket, e.g. "scrapers-{site}".
+	Bucket string
+	// Prefix is the path template the keys start with, e.g. "raw/{yyyy}",
+	// followed by the path the document has under the output root.
+	Prefix string
+	// Assets are globs of files under the output root, separated by commas,
+	// that are uploaded when the run finishes, e.g. "{site}/*.jsonl.gz".
+	Assets string
+	// PartMb is the size of the parts of multipart uploads, which objects
+	// larger than it are uploaded in.
+	PartMb uint64
+	// Images downloads the images of the documents and uploads them to
+	// <prefix>/<site>/images, named after a hash of their URL.
+	Images bool
+}
+
+var s3Options S3Options
+
+// contentTypes are the types of the files documents are saved as, which
+// the system MIME table may not know.
+var contentTypes = map[string]string{
+	".json":  "application/json",
+	".jsonl": "application/x-ndjson",
+	".csv":   "text/csv; charset=utf-8",
+	".md":    "text/markdown; charset=utf-8",
+}
+
+// s3Sink uploads the files and images of every document to S3-compatible
+// storage, the files at the same paths they have under the output root,
+// skipping objects whose content did not change and images already
+// uploaded, and uploads the asset files when the run ends.
+type s3Sink struct {
+	client  *minio.Client
+	buckets map[string]bool
+}
+
+func init() {
+	registerSink("s3", func(flags *flag.FlagSet) {
+		flags.StringVar(&s3Options.Endpoint, "s3-endpoint", "", "upload to the S3-compatible storage at `URL` (default $"+S3Env+")")
+		flags.StringVar(&s3Options.Region, "s3-region", "", "create buckets in `REGION`")
+		flags.StringVar(&s3Options.Bucket, "s3-bucket", "scrapers", "upload to the bucket `TEMPLATE`, e.g. scrapers-{site}")
+		flags.StringVar(&s3Options.Prefix, "s3-prefix", "", "start keys with the path `TEMPLATE`, e.g. raw/{yyyy}")
+		flags.StringVar(&s3Options.Assets, "s3-assets", "", "upload the files matching the comma separated `GLOBS` under the output directory when the run ends")
+		flags.Uint64Var(&s3Options.PartMb, "s3-part-mb", 16, "upload objects larger than `N` megabytes in parts of that size")
+		flags.BoolVar(&s3Options.Images, "s3-images", true, "download the images of documents and upload them too")
+	}, openS3)
+}
+
+func openS3() (Sink, error) {
+	// the environment is read after parsing so that -h does not print the
+	// endpoint, which may carry credentials
+	if s3Options.Endpoint == "" {
+		s3Options.Endpoint = os.Getenv(S3Env)
+	}
+
+	endpoint, err := url.Parse(s3Options.Endpoint)
+	if s3Options.Endpoint == "" || err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("no endpoint, set --s3-endpoint or %s", S3Env)
+	}
+
+	if s3Options.PartMb < 5 {
+		return nil, fmt.Errorf("parts must be at least 5 megabytes, not %d", s3Options.PartMb)
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  credentials.NewEnvAWS(),
+		Secure: endpoint.Scheme == "https",
+		Region: s3Options.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Sink{client: client, buckets: map[string]bool{}}, nil
+}
+
+func (sink *s3Sink) Write(record Record) error {
+	outputs, err := render(record)
+	if err != nil {
+		return err
+	}
+
+	bucket := expandTemplate(s3Options.Bucket, record.Name, record.Doc, "")
+
+	for _, output := range outputs {
+		ext := strings.TrimPrefix(filepath.Ext(output.path), ".")
+		key := path.Join(expandTemplate(s3Options.Prefix, record.Name, record.Doc, ext), expandTemplate(pathTemplate(), record.Name, record.Doc, ext))
+
+		metadata := map[string]string{
+			"Canonical-Id": url.QueryEscape(record.Id),
+			"Saved-At":     record.Saved.UTC().Format(time.RFC3339),
+		}
+
+		err := sink.upload(bucket, key, int64(len(output.body)), checksum(output.body), metadata, func() (io.Reader, error) {
+			return bytes.NewReader(output.body), nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to upload %s/%s: %v", bucket, key, err)
+		}
+	}
+
+	if !s3Options.Images {
+		return nil
+	}
+
+	row := newDocumentRow(record)
+	base, _ := url.Parse(row.link)
+
+	for _, src := range row.images {
+		if base != nil {
+			if resolved, err := base.Parse(src); err == nil {
+				src = resolved.String()
+			}
+		}
+
+		prefix := expandTemplate(s3Options.Prefix, record.Name, record.Doc, "")
+		if err := sink.uploadImage(bucket, prefix, src); err != nil {
+			return fmt.Errorf("unable to upload image %s: %v", src, err)
+		}
+	}
+
+	return nil
+}
+
+// uploadImage downloads the image at src and uploads it under prefix,
+// unless an image was uploaded from src before. Images that cannot be
+// downloaded are skipped.
+func (sink *s3Sink) uploadImage(bucket string, prefix string, src string) error {
+	ctx := context.Background()
+
+	if err := sink.bucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(src))
+	name := path.Join(prefix, site.Name, "images", hex.EncodeToString(sum[:8]))
+
+	// the key ends with the extension of the type of the image, which is
+	// only known once it is downloaded
+	for object := range sink.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: name}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if strings.TrimSuffix(object.Key, path.Ext(object.Key)) == name {
+			return nil
+		}
+	}
+
+	body, err := FetchImage(src)
+	if err != nil {
+		log.Printf("Unable to download image %s: %v", src, err)
+		return nil
+	}
+
+	contentType, ext := imageType(src, body)
+	if !strings.HasPrefix(contentType, "image/") {
+		log.Printf("Unable to upload image %s: not an image but %s", src, contentType)
+		return nil
+	}
+
+	if ext == "" {
+		if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+			ext = extensions[0]
+		}
+	}
+
+	metadata := map[string]string{"Source-Url": url.QueryEscape(src)}
+
+	return sink.upload(bucket, name+ext, int64(len(body)), checksum(body), metadata, func() (io.Reader, error) {
+		return bytes.NewReader(body), nil
+	})
+}
+
+// bucket creates the bucket named name unless it exists.
+func (sink *s3Sink) bucket(ctx context.Context, name string) error {
+	if sink.buckets[name] {
+		return nil
+	}
+
+	exists, err := sink.client.BucketExists(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if err := sink.client.MakeBucket(ctx, name, minio.MakeBucketOptions{Region: s3Options.Region}); err != nil {
+			return err
+		}
+	}
+
+	sink.buckets[name] = true
+
+	return nil
+}
+
+// upload puts the object of size read by open at key, unless the object
+// there already has its sha256.
+func (sink *s3Sink) upload(bucket string, key string, size int64, sum string, metadata map[string]string, open func() (io.Reader, error)) error {
+	ctx := context.Background()
+
+	if err := sink.bucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if info, err := sink.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{}); err == nil {
+		for name, value := range info.UserMetadata {
+			if strings.EqualFold(name, "Sha256") && value == sum {
+				return nil
+			}
+		}
+	} else if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return err
+	}
+
+	contentType, ok := contentTypes[path.Ext(key)]
+	if !ok {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	metadata["Sha256"] = sum
+	metadata["Site"] = site.Name
+
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.client.PutObject(ctx, bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: metadata,
+		PartSize:     s3Options.PartMb * 1024 * 1024,
+	})
+
+	return err
+}
+
+// Close uploads the asset files.
+func (sink *s3Sink) Close() error {
+	for _, glob := range strings.Split(s3Options.Assets, ",") {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+
+		paths, err := filepath.Glob(filepath.Join(options.Output, filepath.FromSlash(expandTemplate(glob, "", nil, ""))))
+		if err != nil {
+			return err
+		}
+
+		for _, asset := range paths {
+			if err := sink.uploadAsset(asset); err != nil {
+				return fmt.Errorf("unable to upload %s: %v", asset, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (sink *s3Sink) uploadAsset(asset string) error {
+	info, err := os.Stat(asset)
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+
+	relative, err := filepath.Rel(options.Output, asset)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(asset)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	bucket := expandTemplate(s3Options.Bucket, "", nil, "")
+	key := path.Join(expandTemplate(s3Options.Prefix, "", nil, ""), filepath.ToSlash(relative))
+
+	return sink.upload(bucket, key, info.Size(), hex.EncodeToString(hash.Sum(nil)), map[string]string{}, func() (io.Reader, error) {
+		_, err := file.Seek(0, io.SeekStart)
+		return file, err
+	})
+}
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standInObject is an object stored by standInS3.
+type standInObject struct {
+	body   []byte
+	header http.Header
+}
+
+// standInS3 is the part of the S3 API the s3 sink uses, with path-style
+// buckets and without checking signatures.
+type standInS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string]standInObject
+	parts   map[string]map[int][]byte
+	puts    int
+	uploads int
+}
+
+func newStandInS3() *standInS3 {
+	return &standInS3{buckets: map[string]bool{}, objects: map[string]standInObject{}, parts: map[string]map[int][]byte{}}
+}
+
+func (s3 *standInS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	body, err := readS3Body(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		if !s3.buckets[bucket] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case key == "" && r.Method == http.MethodPut:
+		s3.buckets[bucket] = true
+	case key == "" && r.Method == http.MethodGet && query.Has("list-type"):
+		s3.list(w, bucket, query.Get("prefix"))
+	case r.Method == http.MethodHead:
+		object, ok := s3.objects[bucket+"/"+key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range object.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.body)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s3.uploads++
+		id := fmt.Sprint(s3.uploads)
+		s3.parts[id] = map[int][]byte{}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, key, id)
+		s3.objects[bucket+"/"+key+"?"+id] = standInObject{header: r.Header.Clone()}
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		s3.parts[query.Get("uploadId")][number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		id := query.Get("uploadId")
+		numbers := make([]int, 0)
+		for number := range s3.parts[id] {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+
+		var joined []byte
+		for _, number := range numbers {
+			joined = append(joined, s3.parts[id][number]...)
+		}
+
+		started := s3.objects[bucket+"/"+key+"?"+id]
+		delete(s3.objects, bucket+"/"+key+"?"+id)
+		s3.objects[bucket+"/"+key] = standInObject{body: joined, header: metadataHeader(started.header)}
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`, bucket, key)
+	case r.Method == http.MethodPut:
+		s3.puts++
+		s3.objects[bucket+"/"+key] = standInObject{body: body, header: metadataHeader(r.Header)}
+		w.Header().Set("ETag", `"etag"`)
+	default:
+		http.Error(w, r.Method+" "+r.URL.String(), http.StatusNotImplemented)
+	}
+}
+
+func (s3 *standInS3) list(w http.ResponseWriter, bucket string, prefix string) {
+	var listing bytes.Buffer
+	listing.WriteString(`<ListBucketResult><Name>` + bucket + `</Name><IsTruncated>false</IsTruncated>`)
+
+	for name, object := range s3.objects {
+		if key := strings.TrimPrefix(name, bucket+"/"); key != name && strings.HasPrefix(key, prefix) {
+			listing.WriteString(`<Contents><Key>`)
+			xml.EscapeText(&listing, []byte(key))
+			fmt.Fprintf(&listing, `</Key><Size>%d</Size><ETag>"etag"</ETag><LastModified>%s</LastModified></Contents>`, len(object.body), time.Now().UTC().Format(time.RFC3339))
+		}
+	}
+
+	listing.WriteString(`</ListBucketResult>`)
+	w.Write(listing.Bytes())
+}
+
+// metadataHeader keeps the content type and user metadata of an upload.
+func metadataHeader(header http.Header) http.Header {
+	kept := http.Header{}
+	for name, values := range header {
+		if name == "Content-Type" || strings.HasPrefix(name, "X-Amz-Meta-") {
+			kept[name] = values
+		}
+	}
+
+	return kept
+}
+
+// readS3Body reads the body of r, decoding the chunks of streaming
+// signatures.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body []byte
+	reader := bufio.NewReader(r.Body)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseInt(strings.Split(strings.TrimSpace(line), ";")[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body, nil
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		body = append(body, chunk[:size]...)
+	}
+}
+
+type s3Doc struct {
+	link  string
+	title string
+	image string
+}
+
+func (doc s3Doc) GetHeaders() []string {
+	return []string{"title", "link", "image"}
+}
+
+func (doc s3Doc) GetValues() []string {
+	return []string{doc.title, doc.link, doc.image}
+}
+
+// openStandInS3 opens the s3 sink on a stand-in storage.
+func openStandInS3(t *testing.T) (*s3Sink, *standInS3) {
+	t.Helper()
+
+	storage := newStandInS3()
+	server := httptest.NewServer(storage)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "scrapers")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "scrapers")
+
+	options.Output = t.TempDir()
+	options.Path = ""
+	site = Site{Name: "example.com"}
+	s3Options = S3Options{Endpoint: server.URL, Region: "us-east-1", Bucket: "scrapers", PartMb: 5, Images: true}
+
+	sink, err := openS3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sink.(*s3Sink), storage
+}
+
+func TestS3EndpointFromEnvironment(t *testing.T) {
+	t.Setenv(S3Env, "http://minio:9000")
+
+	flags := flag.NewFlagSet("scraper", flag.ContinueOnError)
+	sinkTypes["s3"].flags(flags)
+
+	if usage := flags.Lookup("s3-endpoint").DefValue; usage != "" {
+		t.Errorf("usage shows the endpoint: %q", usage)
+	}
+
+	s3Options = S3Options{Bucket: "scrapers", PartMb: 5}
+
+	if _, err := openS3(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s3Options.Endpoint != "http://minio:9000" {
+		t.Errorf("got endpoint %q, want the one of $%s", s3Options.Endpoint, S3Env)
+	}
+}
+
+func TestS3SkipsUnchangedObjects(t *testing.T) {
+	sink, storage := openStandInS3(t)
+
+	doc := s3Doc{link: "https://example.com/1", title: "one"}
+	record := Record{Id: doc.link, Name: "one", Doc: doc, Json: []byte(`{"title": "one"}`), Saved: time.Now()}
+
+	if err := sink.Write(record); err != nil {
+		t.Fatal(err)
+	}
+
+	object, ok := storage.objects["scrapers/example.com/one.json"]
+	if !ok {
+		t.Fatalf("got objects %v, want the files at their paths under the output", storage.objects)
+	}
+	if object.header.Get("Content-Type") != "application/json" || object.header.Get("X-Amz-Meta-Canonical-Id") == "" {
+		t.Errorf("got headers %v, want the content type and metadata", object.header)
+	}
+
+	puts := storage.puts
+	if err := sink.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if storage.puts != puts {
+		t.Errorf("uploaded %d unchanged objects again", storage.puts-puts)
+	}
+
+	record.Json = []byte(`{"title": "one", "changed": true}`)
+	if err := sink.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if storage.puts != puts+1 {
+		t.Errorf("uploaded %d objects, want the changed JSON file", storage.puts-puts)
+	}
+}
+
+func TestS3UploadsImages(t *testing.T) {
+	sink, storage := openStandInS3(t)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	fetches := 0
+
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(png)
+	}))
+	defer images.Close()
+
+	doc := s3Doc{link: images.URL + "/news/1", title: "one", image: "/cover"}
+	record := Record{Id: doc.link, Name: "one", Doc: doc, Json: []byte(`{"title": "one"}`), Saved: time.Now()}
+
+	for run := 0; run < 2; run++ {
+		if err := sink.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploaded := make([]string, 0)
+	for key, object := range storage.objects {
+		if strings.HasPrefix(key, "scrapers/example.com/images/") {
+			uploaded = append(uploaded, key)
+
+			if !bytes.Equal(object.body, png) || object.header.Get("Content-Type") != "image/png" || !strings.HasSuffix(key, ".png") {
+				t.Errorf("got %s of type %s", key, object.header.Get("Content-Type"))
+			}
+		}
+	}
+
+	if len(uploaded) != 1 || fetches != 1 {
+		t.Errorf("got images %v downloaded %d times, want the image downloaded and uploaded once", uploaded, fetches)
+	}
+}
+
+func TestS3UploadsLargeAssetsInParts(t *testing.T) {
+	sink, storage := openStandInS3(t)
+	s3Options.Assets = "{site}/*.jsonl"
+
+	asset := bytes.Repeat([]byte(`{"title": "one"}`+"\n"), 400*1024)
+	if err := os.MkdirAll(filepath.Join(options.Output, "example.com"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(options.Output, "example.com", "example.com.jsonl"), asset, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	object := storage.objects["scrapers/example.com/example.com.jsonl"]
+	if storage.uploads != 1 || !bytes.Equal(object.body, asset) {
+		t.Errorf("got %d multipart uploads and %d bytes, want the %d bytes uploaded in parts", storage.uploads, len(object.body), len(asset))
+	}
+	if object.header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("got content type %q", object.header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// saved counts the documents saved of every source of the run.
+var saved = map[string]int{}
+
+// source is the source documents are saved of, "" for scripts with a single
+// source.
+var source string
+
+// Source starts saving the documents of the source name, e.g. the next
+// tutorial of a guide, for scripts crawling several sources. --limit
+// applies to every source on its own.
+func Source(name string) {
+	source = name
+}
+
+// SourceDone reports whether --limit documents were saved of the current
+// source, so that scripts crawling several sources can move on to the next.
+func SourceDone() bool {
+	return LimitReached(saved[source])
+}
+
+// Save hands doc, named after the title name and the id of doc (see
+// Filename and documentId), to the sinks of the run. With --dry-run the document is
+// printed instead. Once --limit documents were saved of the source, further
+// documents of it are skipped, and a run with a single source is finished.
+func Save(name string, doc Document) {
+	if LimitReached(saved[source]) {
+		return
+	}
+
+	file, err := json.MarshalIndent(doc, "", " ")
+	if err != nil {
+		closeSinks()
+		log.Fatal(err)
+	}
+
+	file, err = versionDocument(doc, []byte(changeKeys(site.Renames, string(file), `"%s"`)))
+	if err != nil {
+		closeSinks()
+		log.Fatal(err)
+	}
+
+	id := documentId(doc, name, file)
+
+	record := Record{
+		Id:    id,
+		Name:  Filename(name, id),
+		Doc:   doc,
+		Json:  file,
+		Saved: time.Now(),
+	}
+
+	if options.DryRun {
+		fmt.Printf("Dry run, not saving %s:\n%s\n", record.Name, record.Json)
+	} else if err := writeSinks(record); err != nil {
+		closeSinks()
+		log.Fatal(err)
+	}
+
+	saved[source]++
+
+	if source == "" && LimitReached(saved[source]) {
+		Finish()
+		os.Exit(0)
+	}
+}
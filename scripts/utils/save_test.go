@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLimitAppliesToEverySource(t *testing.T) {
+	options = Options{Output: t.TempDir(), DryRun: true, Limit: 2}
+	site = Site{Name: "example.com"}
+	saved = map[string]int{}
+	defer Source("")
+
+	for _, name := range []string{"https://example.com/a", "https://example.com/b"} {
+		Source(name)
+
+		// documents past the limit are skipped rather than ending the run
+		for i := 0; i < 3; i++ {
+			link := fmt.Sprintf("%s/%d", name, i)
+			Save(link, tableDoc{link: link, title: link})
+		}
+
+		if !SourceDone() {
+			t.Errorf("source %s is not done", name)
+		}
+	}
+
+	for _, name := range []string{"https://example.com/a", "https://example.com/b"} {
+		if saved[name] != 2 {
+			t.Errorf("saved %d documents of %s, want 2", saved[name], name)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersionField is the field every saved document has the version of
+// its schema in.
+const SchemaVersionField = "schema_version"
+
+// SchemasDir is the folder of the output directory of a site the schemas of
+// its documents are published in, as <type>.v<version>.json.
+const SchemasDir = "schemas"
+
+// jsonSchema is the subset of JSON Schema documents are described with.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Id                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Version              int                    `json:"version,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	// Separator is the separator the items of a list field are joined with,
+	// see Site.Separators.
+	Separator string `json:"x-separator,omitempty"`
+
+	// order are the properties in the order they are marshalled in.
+	order []string
+}
+
+// schemas are the schemas of the document types saved so far, by type.
+var schemas = map[reflect.Type]*jsonSchema{}
+
+// schemaVersion returns the version of the schemas of the site.
+func schemaVersion() int {
+	if site.SchemaVersion == 0 {
+		return 1
+	}
+
+	return site.SchemaVersion
+}
+
+// versionDocument adds the schema version to the JSON of doc and checks it
+// against the schema of its type, publishing the schema the first time a
+// document of the type is saved.
+func versionDocument(doc Document, file []byte) ([]byte, error) {
+	schema, ok := schemas[reflect.TypeOf(doc)]
+	if !ok {
+		schema = documentSchema(doc)
+		schemas[reflect.TypeOf(doc)] = schema
+
+		if !options.DryRun {
+			if err := publishSchema(documentType(doc), schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	file = bytes.TrimRight(file, " \n")
+	if !bytes.HasPrefix(file, []byte("{")) || !bytes.HasSuffix(file, []byte("}")) {
+		return nil, fmt.Errorf("document is not a JSON object")
+	}
+
+	body := bytes.TrimRight(file[:len(file)-1], " \n")
+	field := fmt.Sprintf("\n \"%s\": %d\n}", SchemaVersionField, schemaVersion())
+	if len(body) > 1 {
+		field = "," + field
+	}
+
+	file = append(body[:len(body):len(body)], field...)
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if err := schema.validate(value, ""); err != nil {
+		return nil, fmt.Errorf("document does not match schema %s: %v", schema.Id, err)
+	}
+
+	return file, nil
+}
+
+// documentSchema returns the schema of the JSON files of documents of the
+// type of doc, with the keys renamed by the site.
+func documentSchema(doc Document) *jsonSchema {
+	name := documentType(doc)
+
+	t := reflect.TypeOf(doc)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := typeSchema(t)
+	if schema.Properties == nil {
+		schema.Properties = map[string]*jsonSchema{}
+	}
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.Id = fmt.Sprintf("urn:scrapers:%s:%s:v%d", Slug(site.Name), name, schemaVersion())
+	schema.Title = site.Name + " " + name
+	schema.Version = schemaVersion()
+
+	// keys are renamed in the marshalled JSON, where the first occurrence
+	// of every key is replaced
+	for from, to := range site.Renames {
+		schema.rename(from, to)
+	}
+
+	for field, separator := range site.Separators {
+		if renamed, ok := site.Renames[field]; ok {
+			field = renamed
+		}
+
+		if property, ok := schema.Properties[field]; ok {
+			property.Separator = separator
+		}
+	}
+
+	schema.Properties[SchemaVersionField] = &jsonSchema{Type: "integer", Const: schemaVersion()}
+	schema.order = append(schema.order, SchemaVersionField)
+	schema.Required = append(schema.Required, SchemaVersionField)
+
+	return schema
+}
+
+// rename renames the first property named from, depth first, to to,
+// reporting whether it found one.
+func (schema *jsonSchema) rename(from string, to string) bool {
+	for i, name := range schema.order {
+		if name == from {
+			schema.order[i] = to
+			schema.Properties[to] = schema.Properties[from]
+			delete(schema.Properties, from)
+
+			for j, required := range schema.Required {
+				if required == from {
+					schema.Required[j] = to
+				}
+			}
+
+			return true
+		}
+
+		if schema.Properties[name].rename(from, to) {
+			return true
+		}
+	}
+
+	if schema.Items != nil {
+		return schema.Items.rename(from, to)
+	}
+
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema returns the schema of the values of t as encoding/json
+// marshals them.
+func typeSchema(t reflect.Type) *jsonSchema {
+	if t == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		return &jsonSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := typeSchema(t.Elem())
+		if types := schema.types(); len(types) > 0 {
+			schema.Type = append(types, "null")
+		}
+		return schema
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string"}
+		}
+		// nil slices are marshalled as null
+		return &jsonSchema{Type: []string{"array", "null"}, Items: typeSchema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: []string{"object", "null"}, AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Struct:
+		schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, AdditionalProperties: false}
+		addFields(schema, t)
+		return schema
+	}
+
+	return &jsonSchema{}
+}
+
+// addFields adds the exported fields of the struct type t to schema, as
+// encoding/json marshals them.
+func addFields(schema *jsonSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(schema, field.Type)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := typeSchema(field.Type)
+		if strings.Contains(","+options+",", ",string,") {
+			property = &jsonSchema{Type: "string"}
+		}
+
+		if _, ok := schema.Properties[name]; !ok {
+			schema.order = append(schema.order, name)
+		}
+		schema.Properties[name] = property
+
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// types returns the JSON types the schema allows, or none for any.
+func (schema *jsonSchema) types() []string {
+	switch value := schema.Type.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		types := make([]string, 0)
+		for _, item := range value {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+
+	return nil
+}
+
+// validate checks the JSON value, decoded with numbers as json.Number,
+// against the schema.
+func (schema *jsonSchema) validate(value interface{}, path string) error {
+	if path == "" {
+		path = "document"
+	}
+
+	if types := schema.types(); len(types) > 0 && !contains(types, jsonType(value)) {
+		if !(jsonType(value) == "integer" && contains(types, "number")) {
+			return fmt.Errorf("%s is %s, not %s", path, jsonType(value), strings.Join(types, " or "))
+		}
+	}
+
+	if schema.Const != nil && fmt.Sprint(value) != fmt.Sprint(schema.Const) {
+		return fmt.Errorf("%s is %v, not %v", path, value, schema.Const)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, required := range schema.Required {
+			if _, ok := value[required]; !ok {
+				return fmt.Errorf("%s has no %s", path, required)
+			}
+		}
+
+		for key, item := range value {
+			property, ok := schema.Properties[key]
+			if !ok {
+				switch additional := schema.AdditionalProperties.(type) {
+				case bool:
+					if !additional {
+						return fmt.Errorf("%s has the unknown field %s", path, key)
+					}
+					continue
+				case *jsonSchema:
+					property = additional
+				default:
+					continue
+				}
+			}
+
+			if err := property.validate(item, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range value {
+				if err := schema.Items.validate(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+
+	return "object"
+}
+
+// schemaFile matches the names of published schemas.
+var schemaFile = regexp.MustCompile(`^(.+)\.v(\d+)\.json$`)
+
+// publishSchema writes the schema of the documents of type name to the
+// schemas folder of the site. A schema published under the same version
+// that the new one breaks is kept, and the run fails, until the version of
+// the site is bumped.
+func publishSchema(name string, schema *jsonSchema) error {
+	path := filepath.Join(options.Output, site.Name, SchemasDir, fmt.Sprintf("%s.v%d.json", name, schema.Version))
+
+	if published, err := readSchema(path); err == nil {
+		if breaks := SchemaBreaks(published, schema); len(breaks) > 0 {
+			return fmt.Errorf("the %s documents break schema %s, bump SchemaVersion of the site:\n  %s", name, schema.Id, strings.Join(breaks, "\n  "))
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	body, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	return replace(path, append(body, '\n'))
+}
+
+func readSchema(path string) (*jsonSchema, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(body, schema); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	return schema, nil
+}
+
+// SchemaBreaks returns the changes from the published schema to the
+// current one that break consumers of the published one: removed or
+// renamed fields, fields that became optional and fields whose type
+// changed. Added fields do not break consumers.
+func SchemaBreaks(published *jsonSchema, current *jsonSchema) []string {
+	return schemaBreaks(published, current, "document")
+}
+
+func schemaBreaks(published *jsonSchema, current *jsonSchema, path string) []string {
+	breaks := make([]string, 0)
+
+	if old, types := published.types(), current.types(); len(old) > 0 {
+		for _, t := range types {
+			if !contains(old, t) {
+				breaks = append(breaks, fmt.Sprintf("%s can be %s, not only %s", path, t, strings.Join(old, " or ")))
+			}
+		}
+
+		if len(types) == 0 {
+			breaks = append(breaks, fmt.Sprintf("%s can be anything, not only %s", path, strings.Join(old, " or ")))
+		}
+	}
+
+	if published.Format != current.Format {
+		breaks = append(breaks, fmt.Sprintf("%s is formatted as %q, not %q", path, current.Format, published.Format))
+	}
+
+	if published.Const != nil && fmt.Sprint(published.Const) != fmt.Sprint(current.Const) && path != "document."+SchemaVersionField {
+		breaks = append(breaks, fmt.Sprintf("%s is %v, not %v", path, current.Const, published.Const))
+	}
+
+	names := make([]string, 0, len(published.Properties))
+	for name := range published.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := current.Properties[name]
+		if !ok {
+			breaks = append(breaks, fmt.Sprintf("%s.%s was removed or renamed", path, name))
+			continue
+		}
+
+		if contains(published.Required, name) && !contains(current.Required, name) {
+			breaks = append(breaks, fmt.Sprintf("%s.%s became optional", path, name))
+		}
+
+		breaks = append(breaks, schemaBreaks(published.Properties[name], property, path+"."+name)...)
+	}
+
+	if published.Items != nil && current.Items != nil {
+		breaks = append(breaks, schemaBreaks(published.Items, current.Items, path+"[]")...)
+	}
+
+	return breaks
+}
+
+// SchemaChange is a schema published in a directory of released schemas
+// that the schema of the same site and type in the output directory breaks,
+// without a new version.
+type SchemaChange struct {
+	// Path is the schema in the output directory, relative to it.
+	Path string
+	// Breaks are the breaking changes.
+	Breaks []string
+}
+
+// CheckSchemas compares the newest schemas published under output with the
+// newest ones of the same sites and types under released, which is laid
+// out the same way, e.g. the output of the previous release. It returns the
+// schemas with breaking changes but the same version as the released ones.
+func CheckSchemas(output string, released string) ([]SchemaChange, error) {
+	current, err := newestSchemas(output)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := newestSchemas(released)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make([]SchemaChange, 0)
+	for _, key := range keys {
+		old, ok := previous[key]
+		if !ok {
+			continue
+		}
+
+		change := SchemaChange{Path: current[key]}
+
+		newSchema, err := readSchema(filepath.Join(output, current[key]))
+		if err != nil {
+			return nil, err
+		}
+
+		oldSchema, err := readSchema(filepath.Join(released, old))
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case newSchema.Version < oldSchema.Version:
+			change.Breaks = []string{fmt.Sprintf("version %d is older than the released version %d", newSchema.Version, oldSchema.Version)}
+		case newSchema.Version == oldSchema.Version:
+			change.Breaks = SchemaBreaks(oldSchema, newSchema)
+		}
+
+		if len(change.Breaks) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// newestSchemas returns the path of the newest schema of every site and
+// type under root, by site and type.
+func newestSchemas(root string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "*", SchemasDir, "*.v*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	newest := map[string]string{}
+	versions := map[string]int{}
+
+	for _, path := range paths {
+		match := schemaFile.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[2])
+		key := filepath.Base(filepath.Dir(filepath.Dir(path))) + "/" + match[1]
+
+		if version > versions[key] {
+			relative, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil, err
+			}
+
+			newest[key], versions[key] = filepath.ToSlash(relative), version
+		}
+	}
+
+	return newest, nil
+}
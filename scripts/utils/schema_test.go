@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishSchemaFailsOnBreaks(t *testing.T) {
+	options.Output = t.TempDir()
+	site = Site{Name: "example.com"}
+
+	published := &jsonSchema{
+		Version:    1,
+		Type:       "object",
+		Properties: map[string]*jsonSchema{"title": {Type: "string"}, "link": {Type: "string"}},
+		Required:   []string{"title", "link"},
+	}
+	if err := publishSchema("article", published); err != nil {
+		t.Fatal(err)
+	}
+
+	// added fields do not break the published schema
+	added := &jsonSchema{
+		Version:    1,
+		Type:       "object",
+		Properties: map[string]*jsonSchema{"title": {Type: "string"}, "link": {Type: "string"}, "author": {Type: "string"}},
+		Required:   []string{"title", "link"},
+	}
+	if err := publishSchema("article", added); err != nil {
+		t.Fatal(err)
+	}
+
+	removed := &jsonSchema{
+		Version:    1,
+		Type:       "object",
+		Properties: map[string]*jsonSchema{"title": {Type: "string"}},
+		Required:   []string{"title"},
+	}
+	if err := publishSchema("article", removed); err == nil || !strings.Contains(err.Error(), "document.link was removed or renamed") {
+		t.Errorf("got %v, want the removed field reported", err)
+	}
+
+	body, err := os.ReadFile(filepath.Join(options.Output, "example.com", SchemasDir, "article.v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"link"`) {
+		t.Errorf("published schema was replaced by the broken one:\n%s", body)
+	}
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// KnownGood is the last page on which a watched selector matched.
+type KnownGood struct {
+	Selector string    `json:"selector"`
+	Link     string    `json:"link"`
+	Saved    time.Time `json:"saved"`
+	Html     string    `json:"html"`
+}
+
+type missedPage struct {
+	link          string
+	body          []byte
+	othersMatched bool
+}
+
+type selectorWatch struct {
+	mu        sync.Mutex
+	selectors map[string]string
+	pages     map[*colly.Response]map[string]bool
+	matched   map[string]int
+	missed    map[string]int
+	samples   map[string]missedPage
+	known     map[string]*KnownGood
+}
+
+var watch *selectorWatch
+
+// WatchSelectors tracks on which fetched pages the given field selectors
+// match. The last page where they do is cached as known-good; when a field
+// drifts, Finish prints replacement selectors suggested from a page where it
+// didn't.
+func WatchSelectors(c *colly.Collector, selectors map[string]string) {
+	if watch == nil {
+		watch = &selectorWatch{
+			selectors: map[string]string{},
+			pages:     map[*colly.Response]map[string]bool{},
+			matched:   map[string]int{},
+			missed:    map[string]int{},
+			samples:   map[string]missedPage{},
+			known:     map[string]*KnownGood{},
+		}
+	}
+
+	for field, selector := range selectors {
+		field := field
+
+		watch.selectors[field] = selector
+
+		c.OnHTML(selector, func(h *colly.HTMLElement) {
+			watch.mu.Lock()
+			defer watch.mu.Unlock()
+
+			if watch.pages[h.Response] == nil {
+				watch.pages[h.Response] = map[string]bool{}
+			}
+			watch.pages[h.Response][field] = true
+		})
+	}
+
+	c.OnScraped(func(r *colly.Response) {
+		if !strings.Contains(strings.ToLower(r.Headers.Get("Content-Type")), "html") {
+			return
+		}
+
+		watch.scraped(r, selectors)
+	})
+}
+
+func (w *selectorWatch) scraped(r *colly.Response, selectors map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	matched := w.pages[r]
+	delete(w.pages, r)
+
+	for field, selector := range selectors {
+		if matched[field] {
+			w.matched[field]++
+			w.known[field] = &KnownGood{Selector: selector, Link: r.Request.URL.String(), Saved: time.Now(), Html: string(r.Body)}
+
+			continue
+		}
+
+		w.missed[field]++
+
+		othersMatched := len(matched) > 0
+		if sample, ok := w.samples[field]; ok && sample.othersMatched && !othersMatched {
+			continue
+		}
+
+		w.samples[field] = missedPage{link: r.Request.URL.String(), body: r.Body, othersMatched: othersMatched}
+	}
+}
+
+func knownGoodPath(field string) string {
+	return filepath.Join(options.Output, site.Name, ".selectors", field+".json")
+}
+
+func saveKnownGood(field string, page KnownGood) {
+	if options.DryRun {
+		return
+	}
+
+	path := knownGoodPath(field)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to cache known-good page for %q: %v\n", field, err)
+		return
+	}
+
+	file, err := json.Marshal(page)
+	if err != nil {
+		return
+	}
+
+	if err := os.WriteFile(path, file, 0666); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to cache known-good page for %q: %v\n", field, err)
+	}
+}
+
+func loadKnownGood(field string) *KnownGood {
+	file, err := os.ReadFile(knownGoodPath(field))
+	if err != nil {
+		return nil
+	}
+
+	page := KnownGood{}
+	if err := json.Unmarshal(file, &page); err != nil {
+		return nil
+	}
+
+	return &page
+}
+
+// cache saves the last page of the run every watched field matched on as its
+// known-good page.
+func (w *selectorWatch) cache() {
+	for field, page := range w.known {
+		saveKnownGood(field, *page)
+	}
+}
+
+// report prints repair suggestions for every watched field that drifted or
+// whose selector matched on none of the fetched pages.
+func (w *selectorWatch) report(drifted map[string]bool) {
+	fields := make([]string, 0)
+	for field := range w.samples {
+		if drifted[field] || w.matched[field] == 0 {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		sample := w.samples[field]
+		selector := w.selectors[field]
+
+		fmt.Fprintf(os.Stderr, "SELECTOR REPAIR %s: %q selector %q matched on %d of %d pages, e.g. not on %s\n",
+			site.Name, field, selector, w.matched[field], w.matched[field]+w.missed[field], sample.link)
+
+		known := w.known[field]
+		if known == nil {
+			known = loadKnownGood(field)
+		}
+
+		suggestions, err := SuggestSelectors(sample.body, field, known)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  unable to analyze page: %v\n", err)
+			continue
+		}
+
+		if len(suggestions) == 0 {
+			fmt.Fprintln(os.Stderr, "  no candidates found")
+		}
+
+		for i, suggestion := range suggestions {
+			fmt.Fprintf(os.Stderr, "  %d. %-50s score %.2f  %q\n", i+1, suggestion.Selector, suggestion.Score, suggestion.Preview)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record is a saved document as handed to sinks.
+type Record struct {
+	// Id is the canonical id of the document, its URL or GUID.
+	Id string
+	// Name is the filename the document is saved under, see Filename.
+	Name string
+	// Doc is the document itself.
+	Doc Document
+	// Json is the indented JSON of the document with the keys of the site renamed.
+	Json []byte
+	// Saved is when the document was saved.
+	Saved time.Time
+}
+
+// Fields returns the headers of the document, renamed like its keys, and
+// their values.
+func (record Record) Fields() ([]string, []string) {
+	headers := make([]string, 0)
+	for _, header := range record.Doc.GetHeaders() {
+		if renamed, ok := site.Renames[header]; ok {
+			header = renamed
+		}
+		headers = append(headers, header)
+	}
+
+	return headers, record.Doc.GetValues()
+}
+
+// Sink receives every document a run saves. Sinks are enabled with --sink
+// and closed by Finish.
+type Sink interface {
+	Write(record Record) error
+	Close() error
+}
+
+type sinkType struct {
+	flags func(flags *flag.FlagSet)
+	open  func() (Sink, error)
+}
+
+var sinkTypes = map[string]sinkType{}
+
+var sinks []Sink
+
+// registerSink makes a sink available to --sink under name. flags, when
+// given, registers the options of the sink.
+func registerSink(name string, flags func(flags *flag.FlagSet), open func() (Sink, error)) {
+	sinkTypes[name] = sinkType{flags: flags, open: open}
+}
+
+func sinkNames() []string {
+	names := make([]string, 0)
+	for name := range sinkTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func openSinks() {
+	for _, name := range strings.Split(options.Sinks, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		sinkType, ok := sinkTypes[name]
+		if !ok {
+			closeSinks()
+			log.Fatalf("unknown sink %q, expected one of %s", name, strings.Join(sinkNames(), ", "))
+		}
+
+		sink, err := sinkType.open()
+		if err != nil {
+			closeSinks()
+			log.Fatalf("unable to open %s sink: %v", name, err)
+		}
+
+		sinks = append(sinks, sink)
+	}
+}
+
+// writeSinks hands record to every sink, also when one of them fails, and
+// returns the errors of those that did.
+func writeSinks(record Record) error {
+	failed := make([]string, 0)
+
+	for _, sink := range sinks {
+		if err := sink.Write(record); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to save %s: %s", record.Name, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+func closeSinks() {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to close sink: %v\n", err)
+		}
+	}
+
+	sinks = nil
+}
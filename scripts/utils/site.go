@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"log"
+	"os"
+)
+
+type Document interface {
+	GetHeaders() []string
+	GetValues() []string
+}
+
+// Site describes the source a script scrapes and what a healthy run of it looks like.
+type Site struct {
+	// Name is the output directory of the site, e.g. "coindesk.com".
+	Name string
+	// Path is the template of the paths documents are saved at, FlatPath
+	// when empty. See NestedPath for one directory per document.
+	Path string
+	// Renames maps fields to the keys they are saved under.
+	Renames map[string]string
+	// Type names the kind of the documents of the site in schemas, indexes
+	// and events, e.g. "article". It is the lowercase name of their Go type
+	// when empty, which sites whose type is named otherwise set it for.
+	Type string
+	// Body is the field saved as the body of Markdown files, "content"
+	// when empty. All other fields are saved in the front matter.
+	Body string
+	// Separators maps the fields holding lists, e.g. category, to the
+	// separator their items are joined with, e.g. ", ". Fields without one
+	// hold a single item, e.g. an author named "Jane Doe, PhD". The
+	// separators are published with the schemas, so that the tools reading
+	// the output split the fields the same way.
+	Separators map[string]string
+	// Coverage maps a field to the minimum share of documents it must be
+	// non-empty in, e.g. {"title": 1, "content": 0.95}.
+	Coverage map[string]float64
+	// SchemaVersion is the version of the schemas of the documents, 1 when
+	// zero. Bump it when a change of the documents breaks their published
+	// schemas, e.g. a removed or renamed field.
+	SchemaVersion int
+}
+
+var site Site
+
+func Init(s Site) {
+	site = s
+	coverage = newCoverageReport()
+
+	parseFlags(os.Args[1:])
+
+	if options.DryRun {
+		return
+	}
+
+	if err := os.MkdirAll(options.Output, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+
+	openSinks()
+}
+
+// Finish closes the sinks and ends the run. It exits with DriftExitCode
+// when the extracted documents do not meet the site's coverage expectations,
+// after suggesting repairs for the watched selectors of the drifted fields.
+func Finish() {
+	closeSinks()
+
+	drifted := coverage.Check(site)
+
+	if watch != nil {
+		watch.cache()
+		watch.report(drifted)
+	}
+
+	if len(drifted) > 0 {
+		os.Exit(DriftExitCode)
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SqliteOptions configures the sqlite sink.
+type SqliteOptions struct {
+	// Path is the database file, output/documents.db when empty.
+	Path string
+}
+
+var sqliteOptions SqliteOptions
+
+// sqliteSchema creates the tables of the database. Every statement brings
+// a database at user_version i to version i+1. The FTS5 index refers to
+// documents by docid, which VACUUM keeps, unlike an implicit rowid.
+var sqliteSchema = []string{
+	`CREATE TABLE documents (
+		docid INTEGER PRIMARY KEY,
+		id TEXT NOT NULL UNIQUE,
+		site TEXT NOT NULL,
+		name TEXT NOT NULL,
+		title TEXT,
+		description TEXT,
+		content TEXT,
+		link TEXT,
+		published_at TEXT,
+		fields TEXT NOT NULL,
+		first_seen TEXT NOT NULL,
+		last_seen TEXT NOT NULL
+	);
+	CREATE INDEX documents_site ON documents (site, published_at);
+
+	CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE);
+	CREATE TABLE document_authors (
+		document_id TEXT NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+		author_id INTEGER NOT NULL REFERENCES authors (id),
+		PRIMARY KEY (document_id, author_id)
+	);
+
+	CREATE TABLE categories (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE);
+	CREATE TABLE document_categories (
+		document_id TEXT NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+		category_id INTEGER NOT NULL REFERENCES categories (id),
+		PRIMARY KEY (document_id, category_id)
+	);
+
+	CREATE TABLE images (
+		document_id TEXT NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		PRIMARY KEY (document_id, url)
+	);
+
+	CREATE VIRTUAL TABLE documents_fts USING fts5 (title, description, content, content = 'documents', content_rowid = 'docid');
+
+	CREATE TRIGGER documents_insert AFTER INSERT ON documents BEGIN
+		INSERT INTO documents_fts (rowid, title, description, content) VALUES (new.docid, new.title, new.description, new.content);
+	END;
+	CREATE TRIGGER documents_delete AFTER DELETE ON documents BEGIN
+		INSERT INTO documents_fts (documents_fts, rowid, title, description, content) VALUES ('delete', old.docid, old.title, old.description, old.content);
+	END;
+	CREATE TRIGGER documents_update AFTER UPDATE ON documents BEGIN
+		INSERT INTO documents_fts (documents_fts, rowid, title, description, content) VALUES ('delete', old.docid, old.title, old.description, old.content);
+		INSERT INTO documents_fts (rowid, title, description, content) VALUES (new.docid, new.title, new.description, new.content);
+	END;`,
+}
+
+// sqliteSink upserts every document by its canonical id into a database
+// shared by all sites, with its authors, categories and images in their own
+// tables and its title, description and content in an FTS5 index.
+type sqliteSink struct {
+	db *sql.DB
+}
+
+func init() {
+	registerSink("sqlite", func(flags *flag.FlagSet) {
+		flags.StringVar(&sqliteOptions.Path, "sqlite-path", "", "save documents to the SQLite database `FILE` (default output/documents.db)")
+	}, openSqlite)
+}
+
+func openSqlite() (Sink, error) {
+	path := sqliteOptions.Path
+	if path == "" {
+		path = filepath.Join(options.Output, "documents.db")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+
+	// a single connection keeps the pragmas and serializes the writes
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSqlite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteSink{db: db}, nil
+}
+
+func migrateSqlite(db *sql.DB) error {
+	version := 0
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqliteSchema); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(sqliteSchema[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to migrate to version %d: %v", version+1, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// documentRow holds the columns of a document in the database, as the
+// documents of all sites name them.
+type documentRow struct {
+	id          string
+	title       string
+	description string
+	content     string
+	link        string
+	publishedAt *string
+	authors     []string
+	categories  []string
+	images      []string
+}
+
+func newDocumentRow(record Record) documentRow {
+	row := documentRow{id: record.Id}
+	if row.id == "" {
+		row.id = site.Name + "/" + record.Name
+	}
+
+	if at, ok := publishDate(record.Doc); ok {
+		publishedAt := at.UTC().Format(time.RFC3339)
+		row.publishedAt = &publishedAt
+	}
+
+	values := record.Doc.GetValues()
+
+	for i, header := range record.Doc.GetHeaders() {
+		if i >= len(values) {
+			break
+		}
+
+		value := strings.TrimSpace(values[i])
+
+		switch header {
+		case "title":
+			row.title = value
+		case "description", "excerpt":
+			if row.description == "" {
+				row.description = value
+			}
+		case "content":
+			row.content = value
+		case "link", "url":
+			if row.link == "" {
+				row.link = value
+			}
+		case "author", "authors":
+			row.authors = append(row.authors, listItems(header, value)...)
+		case "category", "categories", "tags":
+			row.categories = append(row.categories, listItems(header, value)...)
+		case "image", "images":
+			row.images = append(row.images, listItems(header, value)...)
+		}
+	}
+
+	return row
+}
+
+func (sink *sqliteSink) Write(record Record) error {
+	row := newDocumentRow(record)
+	saved := record.Saved.UTC().Format(time.RFC3339)
+
+	tx, err := sink.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO documents (id, site, name, title, description, content, link, published_at, fields, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET site = excluded.site, name = excluded.name, title = excluded.title,
+			description = excluded.description, content = excluded.content, link = excluded.link,
+			published_at = excluded.published_at, fields = excluded.fields, last_seen = excluded.last_seen`,
+		row.id, site.Name, record.Name, row.title, row.description, row.content, row.link, row.publishedAt, string(record.Json), saved, saved)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		"DELETE FROM document_authors WHERE document_id = ?",
+		"DELETE FROM document_categories WHERE document_id = ?",
+		"DELETE FROM images WHERE document_id = ?",
+	} {
+		if _, err := tx.Exec(statement, row.id); err != nil {
+			return err
+		}
+	}
+
+	for _, author := range row.authors {
+		if err := linkName(tx, "authors", "document_authors", "author_id", row.id, author); err != nil {
+			return err
+		}
+	}
+
+	for _, category := range row.categories {
+		if err := linkName(tx, "categories", "document_categories", "category_id", row.id, category); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range row.images {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO images (document_id, url) VALUES (?, ?)", row.id, image); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// linkName adds name to table and links the document to it in links.
+func linkName(tx *sql.Tx, table string, links string, column string, id string, name string) error {
+	if _, err := tx.Exec("INSERT INTO "+table+" (name) VALUES (?) ON CONFLICT (name) DO NOTHING", name); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("INSERT OR IGNORE INTO "+links+" (document_id, "+column+") SELECT ?, id FROM "+table+" WHERE name = ?", id, name)
+
+	return err
+}
+
+func (sink *sqliteSink) Close() error {
+	return sink.db.Close()
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSqliteIndexSurvivesVacuum(t *testing.T) {
+	options.Output = t.TempDir()
+	sqliteOptions = SqliteOptions{Path: filepath.Join(options.Output, "documents.db")}
+	site = Site{Name: "example.com"}
+
+	opened, err := openSqlite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := opened.(*sqliteSink)
+	defer sink.Close()
+
+	for _, doc := range []tableDoc{{"https://example.com/1", "alpha"}, {"https://example.com/2", "beta"}, {"https://example.com/3", "gamma"}} {
+		if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Saved: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// VACUUM may renumber the rows of tables without an INTEGER PRIMARY KEY
+	if _, err := sink.db.Exec("DELETE FROM documents WHERE id = 'https://example.com/1'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.db.Exec("VACUUM"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := tableDoc{"https://example.com/3", "delta"}
+	if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Saved: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for term, want := range map[string]string{"beta": "https://example.com/2", "delta": "https://example.com/3", "gamma": ""} {
+		got := ""
+		err := sink.db.QueryRow("SELECT d.id FROM documents_fts JOIN documents d ON d.docid = documents_fts.rowid WHERE documents_fts MATCH ?", term).Scan(&got)
+		if err != nil && want != "" {
+			t.Errorf("searching %q: %v", term, err)
+		}
+		if got != want {
+			t.Errorf("searching %q found %q, want %q", term, got, want)
+		}
+	}
+}
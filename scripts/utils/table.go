@@ -0,0 +1,440 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CsvOptions configures the csv sink.
+type CsvOptions struct {
+	// Scope is "site" for one table per site, or "all" for one table of
+	// every site with a site column.
+	Scope string
+	// Columns lists the columns and their order, separated by commas. All
+	// fields of the documents are written when empty.
+	Columns string
+	// Delimiter separates the columns, "tab" for a tab.
+	Delimiter string
+	// Bom starts the table with a UTF-8 byte order mark for Excel.
+	Bom bool
+	// Truncate cuts values longer than this many characters, 0 means never.
+	Truncate int
+}
+
+var csvOptions CsvOptions
+
+const bom = "\ufeff"
+
+// csvSink keeps a table of one row per document, replaced when a document
+// is saved again, and writes it when the run finishes. Every row is also
+// appended to a journal next to the table as it is written, so that the rows
+// of a run that dies are added to the table by the next one.
+//
+// With --csv-scope all, the sink keeps the table of its own site in
+// <site>/.documents.csv and merges the tables of all sites into
+// documents.csv, so that runs of different sites do not drop each other's
+// rows.
+type csvSink struct {
+	path      string
+	table     string
+	delimiter rune
+	fixed     bool
+	columns   []string
+	rows      [][]string
+	ids       map[string]int
+	journal   *os.File
+}
+
+// journalRow is a row of a table as appended to its journal.
+type journalRow struct {
+	Headers []string `json:"headers"`
+	Values  []string `json:"values"`
+}
+
+// lockTimeout is how long a lock file is waited for before it is taken
+// over as left behind by a run that died.
+const lockTimeout = time.Minute
+
+func init() {
+	registerSink("csv", func(flags *flag.FlagSet) {
+		flags.StringVar(&csvOptions.Scope, "csv-scope", "site", "write one table per `site`, or one table of all sites")
+		flags.StringVar(&csvOptions.Columns, "csv-columns", "", "write the comma separated `COLUMNS` in this order instead of every field")
+		flags.StringVar(&csvOptions.Delimiter, "csv-delimiter", ",", "separate columns with `CHAR`, tab for a tab")
+		flags.BoolVar(&csvOptions.Bom, "csv-bom", false, "start tables with a UTF-8 byte order mark for Excel")
+		flags.IntVar(&csvOptions.Truncate, "csv-truncate", 0, "cut values longer than `N` characters")
+	}, openCsv)
+}
+
+func openCsv() (Sink, error) {
+	sink, err := newCsvSink()
+	if err != nil {
+		return nil, err
+	}
+
+	switch csvOptions.Scope {
+	case "site":
+		sink.path = filepath.Join(options.Output, site.Name, site.Name+".csv")
+		sink.table = sink.path
+	case "all":
+		sink.path = filepath.Join(options.Output, "documents.csv")
+		sink.table = filepath.Join(options.Output, site.Name, ".documents.csv")
+	default:
+		return nil, fmt.Errorf("unknown scope %q", csvOptions.Scope)
+	}
+
+	if err := sink.load(sink.table); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sink.table), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if sink.journal, err = os.OpenFile(sink.table+".journal", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// newCsvSink returns an empty table in the delimiter and columns of the
+// options.
+func newCsvSink() (*csvSink, error) {
+	sink := &csvSink{ids: map[string]int{}}
+
+	delimiter := csvOptions.Delimiter
+	if delimiter == "tab" || delimiter == `\t` {
+		delimiter = "\t"
+	}
+
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return nil, fmt.Errorf("delimiter %q is not a single character", csvOptions.Delimiter)
+	}
+	sink.delimiter, _ = utf8.DecodeRuneInString(delimiter)
+
+	if csvOptions.Columns != "" {
+		sink.fixed = true
+
+		for _, column := range strings.Split(csvOptions.Columns, ",") {
+			sink.columns = append(sink.columns, strings.TrimSpace(column))
+		}
+	} else if csvOptions.Scope == "all" {
+		sink.columns = []string{"site"}
+	}
+
+	return sink, nil
+}
+
+// load reads the rows of the table a previous run wrote at path, followed
+// by the rows of its journal, in the columns of this run.
+func (sink *csvSink) load(path string) error {
+	file, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(file, []byte(bom))))
+	reader.Comma = sink.delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	table, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	if len(table) > 0 {
+		header := table[0]
+
+		for _, row := range table[1:] {
+			fields := map[string]string{}
+			for i, column := range header {
+				if i < len(row) {
+					fields[column] = row[i]
+				}
+			}
+
+			sink.add(header, fields)
+		}
+	}
+
+	return sink.replay(path + ".journal")
+}
+
+// replay adds the rows of the journal at path.
+func (sink *csvSink) replay(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		row := journalRow{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			// the last row of a run that died may be cut short
+			continue
+		}
+
+		fields := map[string]string{}
+		for i, header := range row.Headers {
+			if i < len(row.Values) {
+				fields[header] = row.Values[i]
+			}
+		}
+
+		sink.add(row.Headers, fields)
+	}
+
+	return scanner.Err()
+}
+
+// add puts the row of fields into the table, widened by its headers unless
+// the columns are fixed.
+func (sink *csvSink) add(headers []string, fields map[string]string) {
+	if !sink.fixed {
+		sink.widen(headers)
+	}
+
+	sink.put(rowId(fields), fields)
+}
+
+func (sink *csvSink) widen(columns []string) {
+	for _, column := range columns {
+		found := false
+		for _, existing := range sink.columns {
+			if existing == column {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			sink.columns = append(sink.columns, column)
+		}
+	}
+}
+
+func (sink *csvSink) put(id string, fields map[string]string) {
+	row := make([]string, 0)
+	for _, column := range sink.columns {
+		row = append(row, fields[column])
+	}
+
+	if i, ok := sink.ids[id]; ok {
+		sink.rows[i] = row
+		return
+	}
+
+	sink.ids[id] = len(sink.rows)
+	sink.rows = append(sink.rows, row)
+}
+
+func (sink *csvSink) Write(record Record) error {
+	headers, values := record.Fields()
+
+	ids := map[string]bool{}
+	for _, key := range idKeys() {
+		ids[key] = true
+	}
+
+	row := journalRow{}
+	fields := map[string]string{}
+
+	if csvOptions.Scope == "all" {
+		row.Headers = append(row.Headers, "site")
+		row.Values = append(row.Values, site.Name)
+		fields["site"] = site.Name
+	}
+
+	for i, header := range headers {
+		if i >= len(values) {
+			break
+		}
+
+		value := values[i]
+		if !ids[header] {
+			value = truncate(value, csvOptions.Truncate)
+		}
+
+		row.Headers = append(row.Headers, header)
+		row.Values = append(row.Values, value)
+		fields[header] = value
+	}
+
+	line, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sink.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	sink.add(headers, fields)
+
+	return nil
+}
+
+func (sink *csvSink) Close() error {
+	if err := sink.journal.Close(); err != nil {
+		return err
+	}
+
+	if err := sink.write(sink.table); err != nil {
+		return err
+	}
+
+	if err := os.Remove(sink.table + ".journal"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if csvOptions.Scope == "all" {
+		return mergeCsv(sink.path)
+	}
+
+	return nil
+}
+
+// mergeCsv writes the table of all sites at path from the tables of every
+// site, holding a lock so that runs finishing at the same time do not
+// replace each other's table. Rows of the previous table are kept for sites
+// without a table of their own.
+func mergeCsv(path string) error {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged, err := newCsvSink()
+	if err != nil {
+		return err
+	}
+
+	if err := merged.load(path); err != nil {
+		return err
+	}
+
+	tables, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*", ".documents.csv"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		if err := merged.load(table); err != nil {
+			return err
+		}
+	}
+
+	return merged.write(path)
+}
+
+// lockFile creates the lock file at path, waiting while another run holds
+// it, and returns the function releasing it.
+func lockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(path)
+			continue
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// write replaces the table at path with the rows of the sink.
+func (sink *csvSink) write(path string) error {
+	var table bytes.Buffer
+
+	if csvOptions.Bom {
+		table.WriteString(bom)
+	}
+
+	writer := csv.NewWriter(&table)
+	writer.Comma = sink.delimiter
+
+	writer.Write(sink.columns)
+	for _, row := range sink.rows {
+		// rows loaded before the table was widened are short
+		for len(row) < len(sink.columns) {
+			row = append(row, "")
+		}
+
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	return replace(path, table.Bytes())
+}
+
+// rowId identifies the document of a row by its site and id fields, or by
+// the whole row when it has none.
+func rowId(fields map[string]string) string {
+	for _, key := range idKeys() {
+		if value := fields[key]; value != "" {
+			return fields["site"] + " " + value
+		}
+	}
+
+	return fmt.Sprint(fields)
+}
+
+// idKeys returns the keys the id fields of the site are saved under.
+func idKeys() []string {
+	keys := make([]string, 0)
+	for _, field := range idFields {
+		if renamed, ok := site.Renames[field]; ok {
+			field = renamed
+		}
+		keys = append(keys, field)
+	}
+
+	return keys
+}
+
+// truncate cuts text to limit characters, marking the cut with an ellipsis.
+func truncate(text string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type tableDoc struct {
+	link  string
+	title string
+}
+
+func (doc tableDoc) GetHeaders() []string {
+	return []string{"title", "link"}
+}
+
+func (doc tableDoc) GetValues() []string {
+	return []string{doc.title, doc.link}
+}
+
+func TestCsvAllKeepsRowsOfConcurrentRuns(t *testing.T) {
+	options.Output = t.TempDir()
+	csvOptions = CsvOptions{Scope: "all", Delimiter: ","}
+
+	site = Site{Name: "a.com"}
+	a, err := openCsv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site = Site{Name: "b.com"}
+	b, err := openCsv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site = Site{Name: "a.com"}
+	writeTableDoc(t, a, tableDoc{"https://a.com/1", "A"})
+
+	site = Site{Name: "b.com"}
+	writeTableDoc(t, b, tableDoc{"https://b.com/1", "B"})
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	site = Site{Name: "a.com"}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := readTable(t, filepath.Join(options.Output, "documents.csv"))
+	if len(rows) != 3 {
+		t.Errorf("got %v, want the header and a row of each site", rows)
+	}
+}
+
+func TestCsvKeepsRowsOfRunThatDied(t *testing.T) {
+	options.Output = t.TempDir()
+	csvOptions = CsvOptions{Scope: "site", Delimiter: ","}
+	site = Site{Name: "a.com"}
+
+	died, err := openCsv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTableDoc(t, died, tableDoc{"https://a.com/1", "first"})
+	died.(*csvSink).journal.Close()
+
+	next, err := openCsv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTableDoc(t, next, tableDoc{"https://a.com/2", "second"})
+	if err := next.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := readTable(t, filepath.Join(options.Output, "a.com", "a.com.csv"))
+	if len(rows) != 3 {
+		t.Errorf("got %v, want the header and the rows of both runs", rows)
+	}
+
+	if _, err := os.Stat(filepath.Join(options.Output, "a.com", "a.com.csv.journal")); !os.IsNotExist(err) {
+		t.Errorf("journal is left after the table was written: %v", err)
+	}
+}
+
+func writeTableDoc(t *testing.T, sink Sink, doc tableDoc) {
+	t.Helper()
+
+	if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Saved: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTable(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GlossaryDir is the folder of a vault the glossary term notes are in.
+const GlossaryDir = "Glossary"
+
+// maxNoteName is the longest name of a note, in characters.
+const maxNoteName = 100
+
+// minTermLength is the shortest glossary term mentions of are linked, so
+// terms like "A" or "OG" do not link every other word.
+const minTermLength = 3
+
+// noteUnsafe are the characters note names cannot have, as Obsidian
+// forbids them in file names or links.
+var noteUnsafe = regexp.MustCompile(`[*"\\/<>:|?#^\[\]\x00-\x1f]+`)
+
+// Vault is an Obsidian or Logseq vault of Markdown notes: one note per
+// document, and one note per glossary term merging the definitions of every
+// glossary site. Mentions of the terms become [[wikilinks]] to their notes.
+type Vault struct {
+	dir   string
+	terms map[string]*vaultTerm
+	// mentions are the lowercase terms that are linked, by their first
+	// word, longest first
+	mentions map[string][]string
+	merged   map[string]bool
+	// names are the lowercase names of the notes, which wikilinks refer to
+	// whatever folder the notes are in
+	names map[string]bool
+}
+
+// vaultTerm is a glossary term and its definitions.
+type vaultTerm struct {
+	note        string
+	aliases     []string
+	definitions []Entry
+}
+
+// NewVault returns the vault at dir, with the glossary terms of the entries
+// of the glossary sites.
+func NewVault(dir string, entries []Entry, glossaries []string) *Vault {
+	vault := &Vault{dir: dir, terms: map[string]*vaultTerm{}, mentions: map[string][]string{}, merged: map[string]bool{}, names: map[string]bool{}}
+
+	for _, entry := range entries {
+		if entry.Type != "glossary" || !contains(glossaries, entry.Site) {
+			continue
+		}
+
+		title := entry.Title()
+		key := strings.ToLower(title)
+		if key == "" {
+			continue
+		}
+
+		term := vault.terms[key]
+		if term == nil {
+			term = &vaultTerm{note: vault.uniqueName(noteName(title, entry.Id))}
+			vault.terms[key] = term
+
+			if utf8.RuneCountInString(title) >= minTermLength {
+				word := firstWord(key)
+				vault.mentions[word] = append(vault.mentions[word], key)
+			}
+		}
+
+		if !contains(term.aliases, title) {
+			term.aliases = append(term.aliases, title)
+		}
+		term.definitions = append(term.definitions, entry)
+		vault.merged[entry.Path] = true
+	}
+
+	// longer terms first, so "proof of stake" wins over "proof"
+	for _, keys := range vault.mentions {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return len(keys[i]) > len(keys[j])
+		})
+	}
+
+	return vault
+}
+
+// uniqueName returns name, followed by the first number from 2 that makes
+// it unique among the notes of the vault when another note has it, as terms
+// like "Stake?" and "Stake" or "A/B" and "A B" get the same name.
+func (vault *Vault) uniqueName(name string) string {
+	unique := name
+	for n := 2; vault.names[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s %d", name, n)
+	}
+	vault.names[strings.ToLower(unique)] = true
+
+	return unique
+}
+
+// Terms returns how many glossary terms the vault has.
+func (vault *Vault) Terms() int {
+	return len(vault.terms)
+}
+
+// WriteGlossary writes the note of every glossary term.
+func (vault *Vault) WriteGlossary() error {
+	for _, term := range vault.terms {
+		front := &yaml.Node{Kind: yaml.MappingNode}
+		addFront(front, "title", term.aliases[0])
+		addFront(front, "aliases", term.aliases[1:])
+		addFront(front, "tags", []string{"glossary"})
+
+		sources := make([]string, 0)
+		links := make([]string, 0)
+		for _, definition := range term.definitions {
+			sources = append(sources, definition.Site)
+			if link := definition.Link(); link != "" {
+				links = append(links, link)
+			}
+		}
+		addFront(front, "sources", sources)
+		addFront(front, "links", links)
+
+		var body strings.Builder
+		linked := map[string]bool{strings.ToLower(term.aliases[0]): true}
+
+		for _, definition := range term.definitions {
+			body.WriteString("\n## " + definition.Site + "\n\n")
+
+			if summary := definition.Summary(); summary != "" {
+				body.WriteString("> " + vault.link(PlainText(summary), linked) + "\n\n")
+			}
+
+			if content := HTMLMarkdown(definition.Content(), definition.Link()); content != "" {
+				body.WriteString(vault.linkMarkdown(content, linked) + "\n\n")
+			}
+		}
+
+		if err := vault.writeNote(filepath.Join(GlossaryDir, term.note+".md"), front, body.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write writes the note of entry under the folder of its site, unless it
+// is a glossary term merged into the note of the term.
+func (vault *Vault) Write(entry Entry) (bool, error) {
+	if vault.merged[entry.Path] {
+		return false, nil
+	}
+
+	front := &yaml.Node{Kind: yaml.MappingNode}
+
+	fields := make([]string, 0, len(entry.Fields))
+	for field := range entry.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	addFront(front, "title", entry.Title())
+	for _, field := range fields {
+		if field == "content" || field == "title" || field == "tags" {
+			continue
+		}
+
+		addFront(front, field, entry.Fields[field])
+	}
+
+	tags := []string{entry.Type}
+	for _, category := range entry.Categories() {
+		if tag := Slug(category); tag != "" && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	addFront(front, "tags", tags)
+	addFront(front, "source", entry.Site)
+
+	body := ""
+	if content := HTMLMarkdown(entry.Content(), entry.Link()); content != "" {
+		body = "\n" + vault.linkMarkdown(content, map[string]bool{}) + "\n"
+	}
+
+	name := noteName(entry.Title(), entry.Id)
+	if vault.names[strings.ToLower(name)] {
+		name = Filename(name, entry.Id)
+	}
+	name = vault.uniqueName(name)
+
+	return true, vault.writeNote(filepath.Join(entry.Site, name+".md"), front, body)
+}
+
+// link turns the first mention of every glossary term in text, that is not
+// in linked yet, into a wikilink to its note. Terms are mentioned where they
+// start and end at the boundaries of words, ignoring case.
+func (vault *Vault) link(text string, linked map[string]bool) string {
+	var out strings.Builder
+	last := 0
+	boundary := true
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		if boundary {
+			if key, end := vault.mention(text, i); key != "" {
+				if !linked[key] {
+					linked[key] = true
+
+					mention := text[i:end]
+					out.WriteString(text[last:i])
+					if note := vault.terms[key].note; strings.EqualFold(note, mention) {
+						out.WriteString("[[" + mention + "]]")
+					} else {
+						out.WriteString("[[" + note + "|" + mention + "]]")
+					}
+					last = end
+				}
+
+				i = end
+				boundary = false
+				continue
+			}
+		}
+
+		boundary = !wordRune(r)
+		i += size
+	}
+
+	out.WriteString(text[last:])
+
+	return out.String()
+}
+
+// mention returns the longest term mentioned at start of text and where
+// the mention ends, or no term.
+func (vault *Vault) mention(text string, start int) (string, int) {
+	for _, key := range vault.mentions[firstWord(text[start:])] {
+		end, ok := foldPrefix(text[start:], key)
+		if !ok {
+			continue
+		}
+		end += start
+
+		if next, _ := utf8.DecodeRuneInString(text[end:]); end == len(text) || !wordRune(next) {
+			return key, end
+		}
+	}
+
+	return "", 0
+}
+
+// firstWord returns the letters and digits text starts with, in lowercase.
+func firstWord(text string) string {
+	end := strings.IndexFunc(text, func(r rune) bool { return !wordRune(r) })
+	if end < 0 {
+		end = len(text)
+	}
+
+	return strings.ToLower(text[:end])
+}
+
+// foldPrefix reports whether text starts with the lowercase key, ignoring
+// case, and returns the length of that prefix of text.
+func foldPrefix(text string, key string) (int, bool) {
+	n := 0
+	for _, k := range key {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if size == 0 || unicode.ToLower(r) != k {
+			return 0, false
+		}
+		n += size
+	}
+
+	return n, true
+}
+
+func wordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// markdownSkipped matches the parts of Markdown glossary terms are not
+// linked in: code, links, images and bare URLs.
+var markdownSkipped = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`" + `|!?\[(?:\\.|[^\\\]])*\]\((?:\\.|[^\\)])*\)|https?://\S+`)
+
+// linkMarkdown links the glossary terms of the Markdown content like link,
+// leaving its code, links and images as they are.
+func (vault *Vault) linkMarkdown(content string, linked map[string]bool) string {
+	var out strings.Builder
+	last := 0
+
+	for _, match := range markdownSkipped.FindAllStringIndex(content, -1) {
+		out.WriteString(vault.link(content[last:match[0]], linked))
+		out.WriteString(content[match[0]:match[1]])
+		last = match[1]
+	}
+
+	out.WriteString(vault.link(content[last:], linked))
+
+	return out.String()
+}
+
+func (vault *Vault) writeNote(path string, front *yaml.Node, body string) error {
+	return writeFrontMatter(filepath.Join(vault.dir, path), front, body)
+}
+
+// addFront adds the field to front matter, unless it has no value.
+func addFront(front *yaml.Node, field string, value interface{}) {
+	var node *yaml.Node
+
+	switch value := value.(type) {
+	case nil:
+		return
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		node = frontValue(field, value)
+	case []string:
+		if len(value) == 0 {
+			return
+		}
+		node = &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range value {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+	default:
+		node = &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(value)}
+		}
+	}
+
+	front.Content = append(front.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field}, node)
+}
+
+// noteName returns the name of the note of a document titled title: the
+// title without the characters links cannot have.
+func noteName(title string, id string) string {
+	name := strings.Join(strings.Fields(noteUnsafe.ReplaceAllString(title, " ")), " ")
+	name = strings.Trim(strings.TrimSuffix(truncate(name, maxNoteName), "…"), ". ")
+
+	if name == "" {
+		return Filename(title, id)
+	}
+
+	return name
+}
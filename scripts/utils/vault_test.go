@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func glossaryEntry(title string) Entry {
+	return Entry{
+		Site:   "example.com",
+		Type:   "glossary",
+		Id:     "https://example.com/glossary/" + Slug(title),
+		Path:   "example.com/" + Slug(title) + ".json",
+		Fields: map[string]interface{}{"title": title},
+	}
+}
+
+func TestVaultLinksLongestMentions(t *testing.T) {
+	entries := []Entry{glossaryEntry("Proof of Stake"), glossaryEntry("Stake"), glossaryEntry("Gas"), glossaryEntry("DeFi")}
+	vault := NewVault(t.TempDir(), entries, []string{"example.com"})
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"proof of stake secures the chain", "[[proof of stake]] secures the chain"},
+		{"Stake, then stake again", "[[Stake]], then stake again"},
+		{"gasless stakeholders", "gasless stakeholders"},
+		{"defi gas", "[[defi]] [[gas]]"},
+		{"gas-gas", "[[gas]]-gas"},
+	}
+
+	for _, test := range tests {
+		if got := vault.link(test.text, map[string]bool{}); got != test.want {
+			t.Errorf("link(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestVaultLinksLargeGlossaries(t *testing.T) {
+	entries := make([]Entry, 0)
+	for i := 0; i < 20000; i++ {
+		entries = append(entries, glossaryEntry(fmt.Sprintf("term %d", i)))
+	}
+	vault := NewVault(t.TempDir(), entries, []string{"example.com"})
+
+	text := strings.Repeat("a text without terms, ", 1000) + "term 19999"
+	if got := vault.link(text, map[string]bool{}); !strings.HasSuffix(got, "[[term 19999]]") {
+		t.Errorf("got %q, want the last term linked", got[len(got)-40:])
+	}
+}
+
+func TestVaultSuffixesCollidingNotes(t *testing.T) {
+	dir := t.TempDir()
+	vault := NewVault(dir, []Entry{glossaryEntry("A/B"), glossaryEntry("A B")}, []string{"example.com"})
+
+	if err := vault.WriteGlossary(); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := os.ReadDir(filepath.Join(dir, GlossaryDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("got %d notes, want one for each term", len(notes))
+	}
+}
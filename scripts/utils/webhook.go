@@ -0,0 +1,421 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Environment variables with the endpoints and the signing secret of the
+// webhook sink.
+const (
+	WebhookEnv       = "SCRAPERS_WEBHOOK_URL"
+	WebhookSecretEnv = "SCRAPERS_WEBHOOK_SECRET"
+)
+
+// WebhookOptions configures the webhook sink.
+type WebhookOptions struct {
+	// Urls are the endpoints documents are posted to, separated by commas.
+	Urls string
+	// Template is a text/template file rendering the JSON payload of a
+	// document from its event, see documentEvent. The event itself is
+	// posted when empty.
+	Template string
+	// Batch is the number of documents posted together, as a JSON array of
+	// their payloads when more than one.
+	Batch int
+	// Secret signs the payloads with HMAC-SHA256 when set.
+	Secret string
+	// Retries is how often a failed delivery is retried.
+	Retries int
+}
+
+var webhookOptions WebhookOptions
+
+// webhookBackoff is the wait before the first retry, doubled for every
+// retry after it.
+var webhookBackoff = time.Second
+
+// webhookSink posts every new or changed document to the webhook endpoints.
+// Deliveries that still fail after the retries, or that an endpoint refuses,
+// are appended to output/<site>/webhook-dead-letters.jsonl and posted again
+// when the sink of the next run opens. Every endpoint keeps its own record of
+// the documents it accepted, so that a document is only posted again to the
+// endpoints that failed.
+type webhookSink struct {
+	urls        []string
+	template    *template.Template
+	client      *http.Client
+	delivered   map[string]*changes
+	records     []Record
+	deadLetters string
+	// buried are the documents waiting in the dead letters, by endpoint,
+	// id and checksum
+	buried map[string]bool
+}
+
+// deadLetter is a delivery that failed for good.
+type deadLetter struct {
+	Url     string          `json:"url"`
+	Payload json.RawMessage `json:"payload"`
+	// Documents are the checksums of the documents of the payload, by id,
+	// marked delivered to the endpoint once the payload is.
+	Documents map[string]string `json:"documents,omitempty"`
+	Error     string            `json:"error"`
+	FailedAt  time.Time         `json:"failed_at"`
+}
+
+func init() {
+	registerSink("webhook", func(flags *flag.FlagSet) {
+		flags.StringVar(&webhookOptions.Urls, "webhook-url", "", "post documents to the comma separated `URLS` (default $"+WebhookEnv+")")
+		flags.StringVar(&webhookOptions.Template, "webhook-template", "", "render payloads with the text/template `FILE` instead of posting document events")
+		flags.IntVar(&webhookOptions.Batch, "webhook-batch", 1, "post `N` documents per request, as a JSON array when more than one")
+		flags.StringVar(&webhookOptions.Secret, "webhook-secret", "", "sign payloads with HMAC-SHA256 and `SECRET` (default $"+WebhookSecretEnv+")")
+		flags.IntVar(&webhookOptions.Retries, "webhook-retries", 5, "retry failed deliveries `N` times")
+	}, openWebhook)
+}
+
+func openWebhook() (Sink, error) {
+	// the environment is read after parsing so that -h does not print the
+	// secret or the tokens of the endpoints
+	if webhookOptions.Urls == "" {
+		webhookOptions.Urls = os.Getenv(WebhookEnv)
+	}
+	if webhookOptions.Secret == "" {
+		webhookOptions.Secret = os.Getenv(WebhookSecretEnv)
+	}
+
+	sink := &webhookSink{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		deadLetters: filepath.Join(options.Output, site.Name, "webhook-dead-letters.jsonl"),
+		buried:      map[string]bool{},
+	}
+
+	for _, url := range strings.Split(webhookOptions.Urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			sink.urls = append(sink.urls, url)
+		}
+	}
+
+	if len(sink.urls) == 0 {
+		return nil, fmt.Errorf("no endpoints, set --webhook-url or %s", WebhookEnv)
+	}
+
+	if webhookOptions.Batch <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, not %d", webhookOptions.Batch)
+	}
+
+	if webhookOptions.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative, not %d", webhookOptions.Retries)
+	}
+
+	if webhookOptions.Template != "" {
+		parsed, err := template.New(filepath.Base(webhookOptions.Template)).Funcs(template.FuncMap{
+			"json": func(value interface{}) (string, error) {
+				encoded, err := json.Marshal(value)
+				return string(encoded), err
+			},
+			"truncate": truncate,
+		}).ParseFiles(webhookOptions.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		sink.template = parsed
+	}
+
+	sink.delivered = map[string]*changes{}
+	for _, url := range sink.urls {
+		delivered, err := loadChanges(filepath.Join(options.Output, site.Name, ".webhooked-"+checksum([]byte(url))[:16]))
+		if err != nil {
+			return nil, err
+		}
+		sink.delivered[url] = delivered
+	}
+
+	if err := sink.replay(); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (sink *webhookSink) Write(record Record) error {
+	if len(sink.undelivered(record)) == 0 {
+		return nil
+	}
+
+	// documents are marked delivered after the flush, so a document
+	// saved again before it is only posted once
+	for _, pending := range sink.records {
+		if pending.Id == record.Id && checksum(pending.Json) == checksum(record.Json) {
+			return nil
+		}
+	}
+
+	sink.records = append(sink.records, record)
+
+	if len(sink.records) >= webhookOptions.Batch {
+		return sink.flush()
+	}
+
+	return nil
+}
+
+// undelivered returns the endpoints record was not delivered to yet.
+func (sink *webhookSink) undelivered(record Record) []string {
+	var urls []string
+	for _, url := range sink.urls {
+		if sink.delivered[url].kind(record) != "" {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+// render returns the payload of the document of event.
+func (sink *webhookSink) render(event map[string]interface{}) (json.RawMessage, error) {
+	if sink.template == nil {
+		return json.Marshal(event)
+	}
+
+	var payload bytes.Buffer
+	if err := sink.template.Execute(&payload, event); err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(payload.Bytes()) {
+		return nil, fmt.Errorf("%s renders invalid JSON: %s", webhookOptions.Template, truncate(payload.String(), 200))
+	}
+
+	return payload.Bytes(), nil
+}
+
+// flush posts the pending documents to every endpoint they were not
+// delivered to yet, and marks them delivered to the endpoints accepting them.
+func (sink *webhookSink) flush() error {
+	for _, url := range sink.urls {
+		var payloads []json.RawMessage
+		var records []Record
+
+		for _, record := range sink.records {
+			kind := sink.delivered[url].kind(record)
+			if kind == "" || sink.buried[buriedKey(url, newDocumentRow(record).id, checksum(record.Json))] {
+				continue
+			}
+
+			payload, err := sink.render(documentEvent(kind, record))
+			if err != nil {
+				return err
+			}
+
+			payloads = append(payloads, payload)
+			records = append(records, record)
+		}
+
+		if len(payloads) == 0 {
+			continue
+		}
+
+		var body []byte
+		var err error
+
+		if webhookOptions.Batch == 1 {
+			body = payloads[0]
+		} else if body, err = json.Marshal(payloads); err != nil {
+			return err
+		}
+
+		if err := sink.post(url, body); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to deliver %d documents to %s: %v\n", len(records), url, err)
+
+			documents := map[string]string{}
+			for _, record := range records {
+				documents[newDocumentRow(record).id] = checksum(record.Json)
+			}
+
+			if err := sink.bury(deadLetter{Url: url, Payload: body, Documents: documents, Error: err.Error(), FailedAt: time.Now().UTC()}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, record := range records {
+			sink.delivered[url].mark(record)
+		}
+	}
+
+	sink.records = nil
+
+	return nil
+}
+
+// post delivers body to url, retrying while the endpoint is unreachable or
+// fails with a temporary status.
+func (sink *webhookSink) post(url string, body []byte) error {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookBackoff << (attempt - 1))
+		}
+
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("User-Agent", "scrapers/"+site.Name)
+		request.Header.Set("X-Scrapers-Delivery", checksum(body))
+
+		if webhookOptions.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(webhookOptions.Secret))
+			mac.Write(body)
+			request.Header.Set("X-Scrapers-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		response, err := sink.client.Do(request)
+		if err == nil {
+			reply, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+			response.Body.Close()
+
+			if response.StatusCode < 300 {
+				return nil
+			}
+
+			err = fmt.Errorf("%s", response.Status)
+			if reply := strings.TrimSpace(string(reply)); reply != "" {
+				err = fmt.Errorf("%s: %s", response.Status, reply)
+			}
+
+			if !retryableStatus(response.StatusCode) {
+				return err
+			}
+		}
+
+		if attempt >= webhookOptions.Retries {
+			return err
+		}
+	}
+}
+
+// replay posts the dead letters of previous runs to their endpoints again,
+// and keeps only the ones still failing. Their documents are not posted to
+// the endpoint again until the letters went through. Letters to endpoints
+// that are no longer configured are kept as they are.
+func (sink *webhookSink) replay() error {
+	file, err := os.ReadFile(sink.deadLetters)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var kept [][]byte
+
+	for _, line := range bytes.Split(file, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var letter deadLetter
+		if err := json.Unmarshal(line, &letter); err != nil {
+			return fmt.Errorf("unable to read %s: %v", sink.deadLetters, err)
+		}
+
+		delivered, configured := sink.delivered[letter.Url]
+		if !configured {
+			kept = append(kept, line)
+			continue
+		}
+
+		if err := sink.post(letter.Url, letter.Payload); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to deliver a dead letter to %s again: %v\n", letter.Url, err)
+			kept = append(kept, line)
+			for id, sum := range letter.Documents {
+				sink.buried[buriedKey(letter.Url, id, sum)] = true
+			}
+			continue
+		}
+
+		for id, sum := range letter.Documents {
+			delivered.sums[id] = sum
+		}
+	}
+
+	// the letters that went through are marked delivered before they are
+	// dropped, so that they are not posted a third time if the run fails
+	for _, delivered := range sink.delivered {
+		if err := delivered.save(); err != nil {
+			return err
+		}
+	}
+
+	if len(kept) == 0 {
+		return os.Remove(sink.deadLetters)
+	}
+
+	return replace(sink.deadLetters, append(bytes.Join(kept, []byte("\n")), '\n'))
+}
+
+// bury appends a failed delivery to the dead letters of the site.
+func (sink *webhookSink) bury(letter deadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sink.deadLetters), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(sink.deadLetters, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		for id, sum := range letter.Documents {
+			sink.buried[buriedKey(letter.Url, id, sum)] = true
+		}
+	}
+
+	return err
+}
+
+func buriedKey(url string, id string, sum string) string {
+	return url + " " + id + " " + sum
+}
+
+// Close posts the documents still pending and saves which documents were
+// delivered to every endpoint.
+func (sink *webhookSink) Close() error {
+	if err := sink.flush(); err != nil {
+		return err
+	}
+
+	for _, delivered := range sink.delivered {
+		if err := delivered.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
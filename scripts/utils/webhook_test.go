@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookMarksDeliveredAfterSuccess(t *testing.T) {
+	options.Output = t.TempDir()
+	site = Site{Name: "example.com"}
+	webhookBackoff = 0
+
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	posts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		posts++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	run := func() {
+		t.Helper()
+
+		webhookOptions = WebhookOptions{Urls: server.URL, Batch: 1, Retries: 0}
+
+		sink, err := openWebhook()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc := tableDoc{"https://example.com/1", "one"}
+		if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Json: []byte(`{"title": "one"}`), Saved: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run()
+	status = http.StatusOK
+	run()
+	run()
+
+	if posts != 2 {
+		t.Errorf("got %d posts, want the failed delivery posted again and the delivered one not", posts)
+	}
+}
+
+func TestWebhookRetriesFailedEndpointsOnly(t *testing.T) {
+	options.Output = t.TempDir()
+	site = Site{Name: "example.com"}
+	webhookBackoff = 0
+
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	posts := map[string]int{}
+
+	handler := func(name string, failing bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			posts[name]++
+			if failing {
+				w.WriteHeader(status)
+			}
+		}))
+	}
+
+	up := handler("up", false)
+	defer up.Close()
+	down := handler("down", true)
+	defer down.Close()
+
+	deadLetters := filepath.Join(options.Output, site.Name, "webhook-dead-letters.jsonl")
+
+	run := func(docs ...tableDoc) {
+		t.Helper()
+
+		webhookOptions = WebhookOptions{Urls: up.URL + "," + down.URL, Batch: 1, Retries: 0}
+
+		sink, err := openWebhook()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, doc := range docs {
+			if err := sink.Write(Record{Id: doc.link, Name: doc.title, Doc: doc, Json: []byte(`{"title": "` + doc.title + `"}`), Saved: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	one := tableDoc{"https://example.com/1", "one"}
+
+	run(one)
+	run(one)
+	if posts["up"] != 1 || posts["down"] != 2 {
+		t.Errorf("got %v posts, want the document delivered once and only the dead letter retried on the failing endpoint", posts)
+	}
+
+	letters, err := os.ReadFile(deadLetters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(letters), "\n"); lines != 1 {
+		t.Errorf("got %d dead letters, want the failed delivery buried once", lines)
+	}
+
+	status = http.StatusOK
+	run(one, tableDoc{"https://example.com/2", "two"})
+	if posts["up"] != 2 || posts["down"] != 4 {
+		t.Errorf("got %v posts, want the dead letter replayed and only the new document posted", posts)
+	}
+
+	if _, err := os.Stat(deadLetters); !os.IsNotExist(err) {
+		t.Errorf("delivered dead letters were kept: %v", err)
+	}
+}
+
+func TestWebhookSecretFromEnvironment(t *testing.T) {
+	t.Setenv(WebhookSecretEnv, "secret")
+
+	flags := flag.NewFlagSet("scraper", flag.ContinueOnError)
+	sinkTypes["webhook"].flags(flags)
+
+	if usage := flags.Lookup("webhook-secret").DefValue; strings.Contains(usage, "secret") {
+		t.Errorf("usage shows the secret: %q", usage)
+	}
+
+	options.Output = t.TempDir()
+	site = Site{Name: "example.com"}
+	webhookOptions = WebhookOptions{Urls: "http://localhost", Batch: 1}
+
+	if _, err := openWebhook(); err != nil {
+		t.Fatal(err)
+	}
+
+	if webhookOptions.Secret != "secret" {
+		t.Errorf("got secret %q, want the one of $%s", webhookOptions.Secret, WebhookSecretEnv)
+	}
+}
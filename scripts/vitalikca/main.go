@@ -1,17 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"regexp"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 )
 
@@ -44,26 +40,6 @@ func (page Page) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, page Page) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	---
-	%s`, page.Title, page.Link, page.Published, page.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
-func writeToCsv(csvFile os.File, page Page) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(page.GetHeaders())
-	csvWriter.Write(page.GetValues())
-	csvWriter.Flush()
-}
-
 func scrapePage(c *colly.Collector, href string) {
 	time.Sleep(time.Second * 1)
 	var page Page
@@ -86,28 +62,16 @@ func scrapePage(c *colly.Collector, href string) {
 	})
 
 	c.OnHTML("#doc", func(h *colly.HTMLElement) {
-		if strings.Contains(h.Text, h.ChildText("script")) {
-			str := strings.Replace(h.Text, h.ChildText("script"), "", -1)
-			if !strings.Contains(str, h.ChildText("script")) {
-				page.Content = str
-			}
-		}
-
-		reg, _ := regexp.Compile(`^[\s]+`)
-
-		page.Content = strings.Replace(page.Content, "Dark Mode Toggle", "", -1)
-		page.Content = strings.Replace(page.Content, title, "", -1)
-		page.Content = strings.Replace(page.Content, date, "", -1)
-
-		page.Content = reg.ReplaceAllString(page.Content, "")
+		doc := h.DOM.Clone()
 
-		page.Content = strings.Replace(page.Content, "See all posts", "", -1)
-		page.Content = strings.Replace(page.Content, "\n", "", -1)
-		page.Content = strings.Replace(page.Content, "\t", "", -1)
-		page.Content = strings.Replace(page.Content, "\"", "'", -1)
-		page.Content = strings.Replace(page.Content, h.ChildText("script"), "", -1)
+		doc.Find("*").Each(func(i int, s *goquery.Selection) {
+			text := strings.TrimSpace(s.Text())
+			if text != "" && (text == title || text == date || text == "Dark Mode Toggle" || text == "See all posts") {
+				s.Remove()
+			}
+		})
 
-		page.Content = strings.Replace(page.Content, "  ", "", 1)
+		page.Content = utils.SelectionMarkdown(doc, page.Link)
 	})
 
 	c.Visit(page.Link)
@@ -118,76 +82,28 @@ func scrapePage(c *colly.Collector, href string) {
 }
 
 func savePage(page Page) {
-	title, _ := filenamify.FilenamifyV2(page.Title)
-
-	if err := os.Mkdir(fmt.Sprintf("../../output/vitalik.eth.limo/%s", title), os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		} else {
-			print("Found duplicate: " + title + "\n")
-			return
-		}
-	}
-
-	file, err := json.MarshalIndent(page, "", " ")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	filepathJson := fmt.Sprintf("../../output/vitalik.eth.limo/%s/article.json", title)
-	filepathCsv := fmt.Sprintf("../../output/vitalik.eth.limo/%s/article.csv", title)
-	filepathMd := fmt.Sprintf("../../output/vitalik.eth.limo/%s/article.md", title)
-
-	f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+	utils.Observe(page)
 
-	if err != nil && f == nil {
-		createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-		createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-		createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-		if json_err != nil {
-			log.Fatal(err)
-		}
-
-		if csv_err != nil {
-			log.Fatal(err)
-		}
-
-		if md_err != nil {
-			log.Fatal(err)
-		}
-
-		createdJson.Write(file)
-
-		writeToCsv(*createdCsv, page)
-
-		writeToMd(*createdMd, page)
-
-		m := map[string]string{}
-
-		utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-		createdJson.Close()
-		createdCsv.Close()
-		createdMd.Close()
-	} else {
-		f.Close()
-	}
+	utils.Save(page.Title, page)
 }
 
 func main() {
-	if err := os.Mkdir("../../output/vitalik.eth.limo", os.ModePerm); err != nil {
-		if !os.IsExist(err) {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "vitalik.eth.limo",
+		Path:     utils.NestedPath,
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.9, "content": 0.95},
+	})
 
 	all := make([]string, 0)
 	links := make([]string, 0)
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"title":   "title",
+		"content": "#doc",
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
 		log.Fatal(err)
 	})
@@ -200,6 +116,7 @@ func main() {
 	})
 
 	c.Visit("https://vitalik.eth.limo/")
+	utils.Source("https://vitalik.eth.limo/")
 
 	for _, link := range all {
 		if len(links) == 0 {
@@ -220,6 +137,12 @@ func main() {
 	}
 
 	for _, link := range links {
+		if utils.SourceDone() {
+			break
+		}
+
 		scrapePage(c, link)
 	}
+
+	utils.Finish()
 }
@@ -1,15 +1,13 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
+	"github.com/antchfx/xmlquery"
 	"github.com/cixtor/readability"
 	"github.com/gocolly/colly"
 )
@@ -52,22 +50,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	id: "%s"
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Id, article.Title, article.Link, article.Published, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getRSSArticles() []Article {
 	articles := make([]Article, 0)
 	c := colly.NewCollector(colly.MaxDepth(0))
@@ -89,11 +71,16 @@ func getRSSArticles() []Article {
 
 		r := readability.New()
 
-		cont, _ := r.Parse(strings.NewReader(x.ChildText("content/div")), article.Link)
+		content := x.ChildText("content/div")
+		if entry, ok := x.DOM.(*xmlquery.Node); ok {
+			if div := xmlquery.FindOne(entry, "content/div"); div != nil {
+				content = div.OutputXML(true)
+			}
+		}
 
-		article.Content = strings.Replace(cont.TextContent, "\n", "", -1)
-		article.Content = strings.Replace(article.Content, "\t", "", -1)
-		article.Content = strings.Replace(article.Content, "\"", "'", -1)
+		cont, _ := r.Parse(strings.NewReader(content), article.Link)
+
+		article.Content = utils.HTMLMarkdown(cont.Content, article.Link)
 
 		article.Title = strings.Replace(article.Title, "\"", "", -1)
 
@@ -107,69 +94,23 @@ func getRSSArticles() []Article {
 	return articles
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/web3isdoinggreat.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name: "web3isdoinggreat.com",
+		Renames: map[string]string{
+			"title":     "name",
+			"link":      "social",
+			"published": "created_at",
+		},
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 1, "content": 0.95},
+	})
 
 	rssArticles := getRSSArticles()
 	for _, article := range rssArticles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		filepathJson := fmt.Sprintf("../../output/web3isdoinggreat.com/%s.json", article.Title)
-		filepathCsv := fmt.Sprintf("../../output/web3isdoinggreat.com/%s.csv", article.Title)
-		filepathMd := fmt.Sprintf("../../output/web3isdoinggreat.com/%s.md", article.Title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
+		utils.Observe(article)
 
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			m := map[string]string{
-				"title":     "name",
-				"link":      "social",
-				"published": "created_at",
-			}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }
@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"scripts/utils"
 	"strings"
 	"time"
 
-	"github.com/flytam/filenamify"
 	"github.com/gocolly/colly"
 )
 
@@ -49,22 +45,6 @@ func (article Article) GetValues() []string {
 	return values
 }
 
-func writeToMd(mdFile os.File, article Article) {
-	var input = fmt.Sprintf(`---
-	title: "%s"
-	link: "%s"
-	published: "%s"
-	author: "%s"
-	image: "%s"
-	---
-	%s`,
-		article.Title, article.Link, article.Published, article.Author, article.Image, article.Content)
-
-	input = strings.Replace(input, "\t", "", -1)
-
-	mdFile.Write([]byte(strings.Replace(input, "\"", "", -1)))
-}
-
 func getArticles(c *colly.Collector) []Article {
 	articles := make([]Article, 0)
 
@@ -79,6 +59,10 @@ func getArticles(c *colly.Collector) []Article {
 	})
 
 	c.OnHTML(".aListItem", func(h *colly.HTMLElement) {
+		if utils.LimitReached(len(articles)) {
+			return
+		}
+
 		article := Article{}
 
 		pict := h.ChildAttr("img", "src")
@@ -117,14 +101,10 @@ func getArticles(c *colly.Collector) []Article {
 		})
 
 		c.OnHTML(".entry-content", func(h *colly.HTMLElement) {
-			entry := h.Text
+			entry := utils.ElementMarkdown(h)
 
 			article.Content = strings.Replace(entry, fmt.Sprintf("Author: %s", article.Author), "", -1)
 			article.Content = strings.Replace(article.Content, fmt.Sprintf("Author：%s", article.Author), "", -1)
-
-			article.Content = strings.Replace(article.Content, "\n", "", -1)
-			article.Content = strings.Replace(article.Content, "\t", "", -1)
-			article.Content = strings.Replace(article.Content, "\"", "'", -1)
 		})
 
 		c.Visit(article.Link)
@@ -139,70 +119,25 @@ func getArticles(c *colly.Collector) []Article {
 	return articles
 }
 
-func writeToCsv(csvFile os.File, article Article) {
-	csvWriter := csv.NewWriter(&csvFile)
-	csvWriter.Write(article.GetHeaders())
-	csvWriter.Write(article.GetValues())
-	csvWriter.Flush()
-}
-
 func main() {
-	if err := os.Mkdir("../../output/wutalk.com", os.ModePerm); err != nil {
-		if !strings.Contains(err.Error(), "file exists") {
-			log.Fatal(err)
-		}
-	}
+	utils.Init(utils.Site{
+		Name:     "wutalk.com",
+		Coverage: map[string]float64{"title": 1, "link": 1, "published": 0.9, "content": 0.9},
+	})
 
 	c := colly.NewCollector()
 
+	utils.WatchSelectors(c, map[string]string{
+		"content": ".entry-content",
+	})
+
 	articles := getArticles(c)
 
 	for _, article := range articles {
-		file, err := json.MarshalIndent(article, "", " ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		title, _ := filenamify.FilenamifyV2(article.Title)
+		utils.Observe(article)
 
-		filepathJson := fmt.Sprintf("../../output/wutalk.com/%s.json", title)
-		filepathCsv := fmt.Sprintf("../../output/wutalk.com/%s.csv", title)
-		filepathMd := fmt.Sprintf("../../output/wutalk.com/%s.md", title)
-
-		f, err := os.OpenFile(filepathJson, os.O_RDONLY, 0666)
-
-		if err != nil && f == nil {
-			createdJson, json_err := os.OpenFile(filepathJson, os.O_WRONLY|os.O_CREATE, 0666)
-			createdCsv, csv_err := os.OpenFile(filepathCsv, os.O_WRONLY|os.O_CREATE, 0666)
-			createdMd, md_err := os.OpenFile(filepathMd, os.O_WRONLY|os.O_CREATE, 0666)
-
-			if json_err != nil {
-				log.Fatal(err)
-			}
-
-			if csv_err != nil {
-				log.Fatal(err)
-			}
-
-			if md_err != nil {
-				log.Fatal(err)
-			}
-
-			createdJson.Write(file)
-
-			writeToCsv(*createdCsv, article)
-
-			writeToMd(*createdMd, article)
-
-			m := map[string]string{}
-
-			utils.ChangeKeysByPath(m, createdJson, createdMd, createdCsv)
-
-			createdJson.Close()
-			createdCsv.Close()
-			createdMd.Close()
-		} else {
-			f.Close()
-		}
+		utils.Save(article.Title, article)
 	}
+
+	utils.Finish()
 }